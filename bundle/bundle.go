@@ -0,0 +1,227 @@
+package bundle
+
+/*
+*	
+*	Unpacked bundles
+*	
+*	Lays out a document and its resources as an index.html file next to an
+*	assets/ directory, the way browsers save a complete page, with every
+*	reference rewritten to a relative path. Resources are either collected
+*	by Parse or extracted from the data URLs of an embedded document.
+*	
+*/
+
+import(
+	"io"
+	"mime"
+	"time"
+	"path"
+	"strings"
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/buffermet/epoxy/parser"
+	"github.com/buffermet/epoxy/session"
+)
+
+type File struct {
+	Name string
+	Body []byte
+}
+
+// Extensions that types are commonly saved with, where the system MIME
+// tables list a rarer one first, such as .asc for text/plain.
+var preferredExtensions = map[string]string {
+	"text/plain":                ".txt",
+	"text/html":                 ".html",
+	"text/javascript":           ".js",
+	"application/javascript":    ".js",
+	"application/octet-stream":  ".bin",
+	"image/jpeg":                ".jpg",
+	"image/svg+xml":             ".svg",
+	"image/x-icon":              ".ico",
+	"image/vnd.microsoft.icon":  ".ico",
+	"audio/mpeg":                ".mp3",
+}
+
+// Returns the file extension of mimetype: the usual one if there is one,
+// else the one named after its subtype, e.g. .png for image/png.
+func mimeExtension(mimetype string) string {
+	if media_type, _, err := mime.ParseMediaType(mimetype); err == nil {
+		if extension, ok := preferredExtensions[media_type]; ok {
+			return extension
+		}
+	}
+
+	extensions, _ := mime.ExtensionsByType(mimetype)
+	if len(extensions) == 0 {
+		return ""
+	}
+
+	subtype := mimetype[strings.Index(mimetype, "/") + 1:]
+	subtype = strings.SplitN(subtype, "+", 2)[0]
+
+	for i := 0; i < len(extensions); i++ {
+		if strings.EqualFold(extensions[i], "." + subtype) {
+			return extensions[i]
+		}
+	}
+
+	return extensions[0]
+}
+
+// Returns a file name derived from the contents of resource, so identical
+// resources found at different addresses are only stored once.
+func assetName(resource session.Resource) string {
+	sum := sha256.Sum256(resource.Body)
+
+	extension := path.Ext(strings.SplitN(strings.SplitN(resource.Address, "#", 2)[0], "?", 2)[0])
+	if len(extension) < 2 || len(extension) > 6 {
+		extension = ""
+
+		extension = mimeExtension(resource.Type)
+	}
+
+	if extension == "" {
+		extension = ".bin"
+	}
+
+	return hex.EncodeToString(sum[:])[:16] + strings.ToLower(extension)
+}
+
+// Returns the files of the bundle: root as index.html and every resource in
+// assets/. Root and nested documents reference resources by relative path,
+// references to placeholders are replaced by the placeholder itself.
+func Build(root session.Resource, origin, root_dir string, resources []session.Resource) []File {
+	names := map[string]string{}
+	assets := []session.Resource{}
+
+	for i := 0; i < len(resources); i++ {
+		if _, ok := names[resources[i].Address]; ok {
+			continue
+		}
+
+		if resources[i].Placeholder {
+			names[resources[i].Address] = string(resources[i].Body)
+			continue
+		}
+
+		names[resources[i].Address] = assetName(resources[i])
+		assets = append(assets, resources[i])
+	}
+
+	// paths as seen from the root document and from assets/
+	from_root := map[string]string{}
+	from_assets := map[string]string{}
+
+	for address, name := range names {
+		if _, ok := from_root[address]; ok {
+			continue
+		}
+
+		from_assets[address] = name
+		from_root[address] = name
+
+		for i := 0; i < len(assets); i++ {
+			if assets[i].Address == address {
+				from_root[address] = "assets/" + name
+				break
+			}
+		}
+	}
+
+	files := []File{{"index.html", parser.Rewrite(root.Body, origin, root_dir, from_root)}}
+	written := map[string]bool{}
+
+	for i := 0; i < len(assets); i++ {
+		name := names[assets[i].Address]
+		if written[name] {
+			continue
+		}
+
+		written[name] = true
+
+		body := assets[i].Body
+		if parser.Parsable(assets[i].Type) {
+			body = parser.Rewrite(body, assets[i].Address, root_dir, from_assets)
+		}
+
+		files = append(files, File{"assets/" + name, body})
+	}
+
+	return files
+}
+
+// Decodes the data URLs of body into files in assets/ and rewrites them
+// to relative paths, in body as well as in nested documents. Data URLs
+// that cannot be decoded are left in place and returned as failures.
+func Extract(body []byte) ([]File, []session.Failure) {
+	files := []File{}
+	failures := []session.Failure{}
+	written := map[string]bool{}
+
+	var extract func(body []byte, prefix string) []byte
+
+	extract = func(body []byte, prefix string) []byte {
+		replacements := map[string]string{}
+		data_urls := parser.FindDataURLs(body)
+
+		for i := 0; i < len(data_urls); i++ {
+			mimetype, payload, err := parser.DecodeDataURL(data_urls[i])
+			if err != nil {
+				address := data_urls[i]
+				if len(address) > 48 {
+					address = address[:48] + "..."
+				}
+
+				failures = append(failures, session.Failure{Address: address, Err: err})
+				continue
+			}
+
+			name := assetName(session.Resource{Type: mimetype, Body: payload})
+			replacements[data_urls[i]] = prefix + name
+
+			if written[name] {
+				continue
+			}
+
+			written[name] = true
+
+			if parser.Parsable(mimetype) {
+				payload = extract(payload, "")
+			}
+
+			files = append(files, File{"assets/" + name, payload})
+		}
+
+		return parser.ReplaceDataURLs(body, replacements)
+	}
+
+	body = extract(body, "assets/")
+
+	return append([]File{{"index.html", body}}, files...), failures
+}
+
+func WriteZip(w io.Writer, files []File) error {
+	archive := zip.NewWriter(w)
+	date := time.Now()
+
+	for i := 0; i < len(files); i++ {
+		f, err := archive.CreateHeader(&zip.FileHeader {
+			Name:      files[i].Name,
+			Method:    zip.Deflate,
+			Modified:  date,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = f.Write(files[i].Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
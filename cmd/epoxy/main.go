@@ -0,0 +1,493 @@
+package main
+
+/*
+*	
+*	Launcher
+*	
+*/
+
+import(
+	"os"
+	"sort"
+	"bytes"
+	"errors"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/buffermet/epoxy"
+	"github.com/buffermet/epoxy/log"
+	"github.com/buffermet/epoxy/net"
+	"github.com/buffermet/epoxy/fetch"
+	"github.com/buffermet/epoxy/mhtml"
+	"github.com/buffermet/epoxy/parser"
+	"github.com/buffermet/epoxy/bundle"
+	"github.com/buffermet/epoxy/warc"
+	"github.com/buffermet/epoxy/session"
+)
+
+func confirm(count int) bool {
+	answer := log.Prompt("fetch at least " + strconv.Itoa(count) + " resource(s)? Y/n")
+
+	return answer != "n" && answer != "N"
+}
+
+// Returns a fetcher answering from the recorded archives and local mirrors
+// given on the command line, or from the network if there are none.
+func newFetcher(cli *session.CLI) (epoxy.Fetcher, error) {
+	if len(cli.Replays) == 0 && len(cli.Mirrors) == 0 {
+		return fetch.HTTP, nil
+	}
+
+	chain := fetch.Chain{}
+
+	for i := 0; i < len(cli.Replays); i++ {
+		log.Info("loading recorded responses from " + log.BOLD + cli.Replays[i] + log.RESET + " ...")
+
+		replay, err := fetch.OpenReplay(cli.Replays[i])
+		if err != nil {
+			return nil, &session.ConfigError{Reason: "invalid replay file", Value: cli.Replays[i], Err: err}
+		}
+
+		chain = append(chain, replay)
+	}
+
+	for i := 0; i < len(cli.Mirrors); i++ {
+		chain = append(chain, fetch.Dir{Root: cli.Mirrors[i]})
+	}
+
+	return chain, nil
+}
+
+// Reads the source file, or stdin if the source is -. URL sources are
+// retrieved by the embedder instead.
+func readSource(s *session.SessionConfig) error {
+	var err error
+
+	if s.Source == "-" {
+		s.Body, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		s.Body, err = ioutil.ReadFile(s.Source)
+	}
+
+	if err != nil {
+		return &session.ConfigError{Reason: "invalid source file", Value: s.Source, Err: err}
+	}
+
+	return nil
+}
+
+func embed(s *session.SessionConfig, cli *session.CLI, recorder *epoxy.Recorder) (*epoxy.Result, error) {
+	fetcher, err := newFetcher(cli)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []epoxy.Option{
+		epoxy.WithOrigin(s.Origin),
+		epoxy.WithDepth(s.Recurse),
+		epoxy.WithAccept(s.Accept),
+		epoxy.WithReject(s.Reject),
+		epoxy.WithRules(s.Rules),
+		epoxy.WithDenied(s.Denied),
+		epoxy.WithScope(s.Scope.Mode, s.Scope.Hosts...),
+		epoxy.WithLimits(s.Limits),
+		epoxy.WithHeaders(s.Headers),
+		epoxy.WithCookieJar(s.Jar),
+		epoxy.WithBrowser(s.Browser),
+		epoxy.WithUserAgent(s.UserAgent),
+		epoxy.WithTransport(s.Transport),
+		epoxy.WithCache(s.Cache),
+		epoxy.WithOffline(s.Offline),
+		epoxy.WithRetry(s.Retry),
+		epoxy.WithPlaceholder(s.Placeholder),
+		epoxy.WithFetcher(fetcher),
+		epoxy.WithRoot(s.Root),
+		epoxy.WithKeepReferences(cli.Format != "html"),
+		epoxy.WithRecorder(recorder),
+	}
+
+	// stdin can't answer the prompt once the source has been read from it
+	if s.Source != "-" {
+		options = append(options, epoxy.WithConfirm(confirm))
+	}
+
+	e := epoxy.New(options...)
+
+	name := s.Source
+	if s.Origin != "" {
+		name = s.Origin
+	}
+
+	if session.IsURL(s.Source) {
+		if s.Recurse > 0 {
+			return e.EmbedURL(s.Source)
+		}
+
+		body, location, err := e.Fetch(s.Source)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Body = body
+		if s.Origin == "" {
+			name = location
+		}
+	} else {
+		err := readSource(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.Recurse > 0 {
+			return e.EmbedBytes(s.Body, s.Origin)
+		}
+	}
+
+	body, err := e.Encode(s.Body, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &epoxy.Result{Body: body}, nil
+}
+
+// Returns the file name that payloads are saved under, derived from the
+// last path segment of URL sources.
+func sourceName(s *session.SessionConfig) string {
+	if s.Source == "-" {
+		return "stdin"
+	}
+
+	if session.IsURL(s.Source) {
+		name := regexp.MustCompile(`(?:\?|#).*$`).ReplaceAllString(s.Source, "")
+		name = regexp.MustCompile(`(?i)^[a-z]+://[^/]*`).ReplaceAllString(name, "")
+		name = filepath.Base(name)
+
+		if name == "" || name == "." || name == "/" {
+			return "index.html"
+		}
+
+		return name
+	}
+
+	return s.Source
+}
+
+// Returns the path that the payload is saved as, next to the source file
+// unless -output is given.
+func outputPath(s *session.SessionConfig, cli *session.CLI) string {
+	if cli.Output != "" {
+		return cli.Output
+	}
+
+	name := sourceName(s)
+
+	if cli.Command == "extract" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + "-extracted"
+
+		if cli.Format == "zip" {
+			name = name + ".zip"
+		}
+
+		return name
+	}
+
+	if s.Recurse > 0 && cli.Format == "dir" {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	} else if s.Recurse > 0 && cli.Format != "html" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + "." + cli.Format
+	}
+
+	if s.Recurse > 0 {
+		return filepath.Join(filepath.Dir(name), "epoxy-" + filepath.Base(name))
+	}
+
+	return name + ".url"
+}
+
+func initiateWrite(s *session.SessionConfig, cli *session.CLI) (*epoxy.Result, error) {
+	path := outputPath(s, cli)
+
+	if s.Recurse > 0 {
+		log.Info("parsing " + s.Source + " ...")
+	} else {
+		log.Info("encoding " + s.Source + " ...")
+	}
+
+	var recorder *epoxy.Recorder
+	if cli.Format == "warc" {
+		recorder = &epoxy.Recorder{}
+	}
+
+	result, err := embed(s, cli, recorder)
+	if err != nil {
+		return result, err
+	}
+
+	payload := result.Body
+
+	if s.Recurse > 0 && cli.Format == "mhtml" {
+		log.Info("archiving " + strconv.Itoa(len(result.Resources)) + " resource(s) as MHTML ...")
+
+		var archive bytes.Buffer
+
+		root := epoxy.Resource{Type: "text/html", Address: result.Location, Body: result.Body}
+
+		err = mhtml.Write(&archive, root, result.Resources)
+		if err != nil {
+			return result, err
+		}
+
+		payload = archive.Bytes()
+	}
+
+	if s.Recurse > 0 && cli.Format == "warc" {
+		log.Info("archiving " + strconv.Itoa(len(recorder.Exchanges)) + " HTTP exchange(s) as WARC ...")
+
+		var archive bytes.Buffer
+
+		root := epoxy.Resource{Type: "text/html", Address: result.Location, Body: result.Body}
+
+		err = warc.Write(&archive, filepath.Base(path), strings.HasSuffix(path, ".gz"), root, result.Resources, recorder.Exchanges)
+		if err != nil {
+			return result, err
+		}
+
+		payload = archive.Bytes()
+	}
+
+	if s.Recurse > 0 && (cli.Format == "dir" || cli.Format == "zip") {
+		root := epoxy.Resource{Type: "text/html", Address: result.Location, Body: result.Body}
+
+		origin, err := session.NormalizeOrigin(result.Location)
+		if err != nil {
+			return result, err
+		}
+
+		return result, writeFiles(path, bundle.Build(root, origin, s.Root, result.Resources), cli)
+	}
+
+	if path == "-" {
+		_, err = os.Stdout.Write(payload)
+		return result, err
+	}
+
+	log.Info("saving payload as " + log.BOLD + path + log.RESET + " ...")
+
+	return result, writeFile(path, payload, cli.Mode)
+}
+
+// Reads the source into s.Body, retrieving it like any other document if
+// it is a URL, and returns the address it was eventually retrieved from.
+func loadSource(s *session.SessionConfig, cli *session.CLI) (string, error) {
+	if !session.IsURL(s.Source) {
+		return s.Source, readSource(s)
+	}
+
+	fetcher, err := newFetcher(cli)
+	if err != nil {
+		return "", err
+	}
+
+	body, location, err := epoxy.New(epoxy.WithRetry(s.Retry), epoxy.WithFetcher(fetcher), epoxy.WithHeaders(s.Headers), epoxy.WithCookieJar(s.Jar), epoxy.WithBrowser(s.Browser), epoxy.WithUserAgent(s.UserAgent), epoxy.WithTransport(s.Transport), epoxy.WithCache(s.Cache), epoxy.WithOffline(s.Offline)).Fetch(s.Source)
+	if err != nil {
+		return "", err
+	}
+
+	s.Body = body
+
+	return location, nil
+}
+
+// Decodes the data URLs of the source into files.
+func initiateExtract(s *session.SessionConfig, cli *session.CLI) (*epoxy.Result, error) {
+	path := outputPath(s, cli)
+
+	log.Info("extracting data URLs from " + s.Source + " ...")
+
+	_, err := loadSource(s, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	files, failures := bundle.Extract(s.Body)
+
+	log.Success("extracted " + strconv.Itoa(len(files) - 1) + " file(s).")
+
+	result := &epoxy.Result{Location: s.Source, Body: files[0].Body, Failures: failures}
+
+	return result, writeFiles(path, files, cli)
+}
+
+// Lists the resources referenced by the source on stdout, resolved against
+// its origin, followed by the type and size of its data URLs.
+func initiateInspect(s *session.SessionConfig, cli *session.CLI) (*epoxy.Result, error) {
+	location, err := loadSource(s, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	origin := s.Origin
+	if origin == "" {
+		origin = location
+	}
+
+	// "" for local sources without -origin, which are listed unresolved
+	origin, _ = session.NormalizeOrigin(origin)
+
+	result := &epoxy.Result{Location: location, Body: s.Body}
+	str := ""
+
+	references := parser.FindReferences(s.Body)
+
+	for i := 0; i < len(references); i++ {
+		address := references[i]
+
+		if origin != "" && !regexp.MustCompile(`(?i)^(?:javascript:|#)`).MatchString(address) {
+			address, err = parser.Resolve(references[i], origin, s.Root)
+			if err != nil {
+				result.Failures = append(result.Failures, epoxy.Failure{Address: references[i], Err: err})
+				continue
+			}
+		}
+
+		str += address + "\n"
+	}
+
+	data_urls := parser.FindDataURLs(s.Body)
+
+	for i := 0; i < len(data_urls); i++ {
+		mimetype, body, err := parser.DecodeDataURL(data_urls[i])
+		if err != nil {
+			result.Failures = append(result.Failures, epoxy.Failure{Address: data_urls[i], Err: err})
+			continue
+		}
+
+		str += "data:" + mimetype + " " + strconv.Itoa(len(body)) + " B\n"
+	}
+
+	_, err = os.Stdout.WriteString(str)
+
+	log.Success("found " + strconv.Itoa(len(references)) + " reference(s) and " + strconv.Itoa(len(data_urls)) + " data URL(s) in " + log.BOLD + s.Source + log.RESET + ".")
+
+	return result, err
+}
+
+func showSummary(result *epoxy.Result) {
+	if result == nil {
+		return
+	}
+
+	if len(result.Denials) > 0 {
+		rules := []string{}
+		total := 0
+
+		for rule, count := range result.Denials {
+			rules = append(rules, rule)
+			total += count
+		}
+
+		sort.Strings(rules)
+
+		log.Raw("")
+		log.Info("denied " + strconv.Itoa(total) + " resource(s):")
+
+		for i := 0; i < len(rules); i++ {
+			log.Info(strconv.Itoa(result.Denials[rules[i]]) + " by " + rules[i])
+		}
+	}
+
+	connections := result.Connections
+	if connections.Opened + connections.Reused > 0 {
+		log.Raw("")
+		log.Info("sent " + strconv.Itoa(connections.Opened + connections.Reused) + " request(s), opened " + strconv.Itoa(connections.Opened) + " connection(s) and reused " + strconv.Itoa(connections.Reused) + ", " + strconv.Itoa(connections.HTTP2) + " answered over HTTP/2.")
+	}
+
+	if len(result.Failures) == 0 {
+		return
+	}
+
+	log.Raw("")
+	log.Warn("failed to retrieve " + strconv.Itoa(len(result.Failures)) + " resource(s):")
+
+	for i := 0; i < len(result.Failures); i++ {
+		log.Error(result.Failures[i].Address + " (" + result.Failures[i].Err.Error() + ")")
+	}
+}
+
+func main() {
+	s, cli, err := session.NewSession(os.Args[1:])
+
+	// keep stdout clean for the payload
+	if cli.Output == "-" || cli.Command == "inspect" || cli.Command == "completion" {
+		log.Output = os.Stderr
+	}
+
+	log.Raw("")
+
+	if errors.Is(err, session.ErrHelp) {
+		session.ShowOptions(cli.Command)
+		os.Exit(0)
+	} else if err != nil {
+		log.Error(err.Error() + "\n")
+		session.ShowOptions(cli.Command)
+		os.Exit(2)
+	}
+
+	if cli.Command == "completion" {
+		script, err := session.Completion(cli.Shell)
+		if err != nil {
+			log.Error(err.Error() + "\n")
+			os.Exit(2)
+		}
+
+		os.Stdout.WriteString(script)
+		os.Exit(0)
+	}
+
+	if cli.Insecure {
+		log.Warn(log.BOLD + "TLS certificates are not verified (-insecure), anyone on the network can alter the payload." + log.RESET)
+		log.Raw("")
+	}
+
+	runtime.GOMAXPROCS(cli.Cores)
+
+	var result *epoxy.Result
+
+	switch cli.Command {
+	case "extract":
+		result, err = initiateExtract(s, cli)
+	case "inspect":
+		result, err = initiateInspect(s, cli)
+	default:
+		result, err = initiateWrite(s, cli)
+	}
+
+	showSummary(result)
+
+	// an offline payload missing resources must not pass for a complete one
+	if s.Offline && err == nil && result != nil {
+		misses := 0
+		for i := 0; i < len(result.Failures); i++ {
+			if errors.Is(result.Failures[i].Err, net.ErrCacheMiss) {
+				misses++
+			}
+		}
+
+		if misses > 0 {
+			err = errors.New(strconv.Itoa(misses) + " resource(s) missing from the cache, the payload is incomplete")
+		}
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+		log.Raw("")
+		os.Exit(1)
+	}
+
+	log.Raw("")
+}
@@ -0,0 +1,94 @@
+package main
+
+/*
+*	
+*	Payload output
+*	
+*/
+
+import(
+	"os"
+	"bytes"
+	"strconv"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/buffermet/epoxy/log"
+	"github.com/buffermet/epoxy/bundle"
+	"github.com/buffermet/epoxy/session"
+)
+
+// Writes body to path by way of a temporary file in the same directory,
+// so the payload is either written completely or not at all.
+func writeFile(path string, body []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "." + filepath.Base(path) + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(body)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if close_err := tmp.Close(); err == nil {
+		err = close_err
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// Saves files in the directory at path, or packed in a zip file if the
+// output format of cli is zip.
+func writeFiles(path string, files []bundle.File, cli *session.CLI) error {
+	if cli.Format != "zip" {
+		if path == "-" {
+			return &session.ConfigError{Reason: "cannot write a directory to", Value: "stdout"}
+		}
+
+		log.Info("saving " + strconv.Itoa(len(files)) + " file(s) in " + log.BOLD + path + log.RESET + " ...")
+
+		for i := 0; i < len(files); i++ {
+			err := writeFile(filepath.Join(path, filepath.FromSlash(files[i].Name)), files[i].Body, cli.Mode)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	log.Info("packing " + strconv.Itoa(len(files)) + " file(s) as ZIP ...")
+
+	var archive bytes.Buffer
+
+	err := bundle.WriteZip(&archive, files)
+	if err != nil {
+		return err
+	}
+
+	if path == "-" {
+		_, err = os.Stdout.Write(archive.Bytes())
+		return err
+	}
+
+	log.Info("saving payload as " + log.BOLD + path + log.RESET + " ...")
+
+	return writeFile(path, archive.Bytes(), cli.Mode)
+}
@@ -0,0 +1,347 @@
+package epoxy
+
+/*
+*	
+*	Library interface
+*	
+*	Embeds the resources of HTML, CSS and SVG documents using data URLs,
+*	or encodes single files as data URLs.
+*	
+*/
+
+import(
+	"io"
+	"net/http"
+	"io/ioutil"
+
+	"github.com/buffermet/epoxy/net"
+	"github.com/buffermet/epoxy/fetch"
+	"github.com/buffermet/epoxy/parser"
+	"github.com/buffermet/epoxy/session"
+)
+
+type RetryPolicy = session.RetryPolicy
+type Resource = session.Resource
+type Failure = session.Failure
+type Fetcher = session.Fetcher
+type Recorder = session.Recorder
+type Exchange = session.Exchange
+type Rule = session.Rule
+type Rules = session.Rules
+type Limits = session.Limits
+type Connections = session.Connections
+
+type Result struct {
+	Location string
+	Body []byte
+	Resources []Resource
+	Failures []Failure
+	Denials map[string]int
+	Connections Connections
+}
+
+type Embedder struct {
+	origin string
+	depth int
+	options session.Options
+}
+
+type Option func(*Embedder)
+
+// URL that relative paths are resolved against when Embed is called
+// without a base URL.
+func WithOrigin(origin string) Option {
+	return func(e *Embedder) {
+		e.origin = origin
+	}
+}
+
+// Limit of recursions for resource embedding (default=1).
+func WithDepth(depth int) Option {
+	return func(e *Embedder) {
+		e.depth = depth
+	}
+}
+
+// MIME types that may be embedded, replacing session.DefaultAccept().
+// Wildcards such as image/* are allowed, see session.ParseMimetypes for
+// turning file extensions into MIME types.
+func WithAccept(accept []string) Option {
+	return func(e *Embedder) {
+		e.options.Accept = accept
+	}
+}
+
+// MIME types that are not embedded unless accepted by a more specific
+// pattern, so image/* rejects image/avif but not image/png of
+// session.DefaultAccept(), use WithAccept to narrow those down.
+func WithReject(reject []string) Option {
+	return func(e *Embedder) {
+		e.options.Reject = reject
+	}
+}
+
+func WithRetry(policy RetryPolicy) Option {
+	return func(e *Embedder) {
+		e.options.Retry = policy
+	}
+}
+
+// Replaces references to resources that cannot be retrieved, which are
+// left untouched by default.
+func WithPlaceholder(placeholder string) Option {
+	return func(e *Embedder) {
+		e.options.Placeholder = placeholder
+	}
+}
+
+// Called with the number of resources found in the root document before
+// anything is fetched, returning false cancels the run.
+func WithConfirm(confirm func(count int) bool) Option {
+	return func(e *Embedder) {
+		e.options.Confirm = confirm
+	}
+}
+
+// Retrieves resources through f instead of net.SendRequest, see the fetch
+// package for implementations.
+func WithFetcher(f Fetcher) Option {
+	return func(e *Embedder) {
+		e.options.Fetcher = f
+	}
+}
+
+// Confines file:// origins to dir, paths starting with a slash found in
+// documents below it resolve against dir instead of the filesystem root.
+func WithRoot(dir string) Option {
+	return func(e *Embedder) {
+		e.options.Root = dir
+	}
+}
+
+// Collects the resources of a document and of the documents nested in it
+// without embedding them, leaving Result.Body untouched and the original
+// bodies in Result.Resources, e.g. for writing archives with the mhtml
+// package.
+func WithKeepReferences(keep bool) Option {
+	return func(e *Embedder) {
+		e.options.KeepReferences = keep
+	}
+}
+
+// Hands every HTTP exchange to recorder, e.g. for writing archives with
+// the warc package.
+func WithRecorder(recorder *Recorder) Option {
+	return func(e *Embedder) {
+		e.options.Recorder = recorder
+	}
+}
+
+// Allows or denies the URLs of resources before they are retrieved, the
+// first matching rule decides and URLs that match none are allowed.
+func WithRules(rules Rules) Option {
+	return func(e *Embedder) {
+		e.options.Rules = rules
+	}
+}
+
+// What references to denied URLs are replaced with: session.DeniedKeep
+// leaves them untouched (default), session.DeniedBlank empties them and
+// session.DeniedAboutBlank points them to about:blank.
+func WithDenied(action string) Option {
+	return func(e *Embedder) {
+		e.options.Denied = action
+	}
+}
+
+// Only retrieves resources in scope of the root document, at every level
+// of recursion: session.ScopeSameOrigin, session.ScopeSameSite (same
+// registrable domain) or session.ScopeHosts. Resources on hosts are in
+// scope in every mode, a leading dot includes subdomains. Out of scope
+// references are treated like denied ones, see WithDenied.
+func WithScope(mode string, hosts ...string) Option {
+	return func(e *Embedder) {
+		e.options.Scope = session.Scope{Mode: mode, Hosts: hosts}
+	}
+}
+
+// Limits the size of resources, downloads are aborted as soon as a limit
+// is exceeded and the reference is handled as set by Limits.Oversize.
+func WithLimits(limits Limits) Option {
+	return func(e *Embedder) {
+		e.options.Limits = limits
+	}
+}
+
+// Sends header with every request, replacing the default User-Agent and
+// Referer headers if it sets them.
+func WithHeaders(header http.Header) Option {
+	return func(e *Embedder) {
+		e.options.Headers = header
+	}
+}
+
+// Sends the cookies in jar with every request and stores the cookies set
+// by responses in it, see session.ReadCookies for cookies.txt files.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(e *Embedder) {
+		e.options.Jar = jar
+	}
+}
+
+// Sends the headers of a browser profile of session.Browsers, "chrome" by
+// default, so servers return the variants of resources a browser gets.
+func WithBrowser(name string) Option {
+	return func(e *Embedder) {
+		e.options.Browser = name
+	}
+}
+
+// Sends user_agent instead of the User-Agent of the browser profile.
+func WithUserAgent(user_agent string) Option {
+	return func(e *Embedder) {
+		e.options.UserAgent = user_agent
+	}
+}
+
+// Sends requests through transport, e.g. one made by session.NewTransport
+// for a proxy or custom certificate authorities, instead of the default
+// transport of net/http.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(e *Embedder) {
+		e.options.Transport = transport
+	}
+}
+
+// Keeps responses in dir and serves them from there while they are fresh,
+// revalidating stale ones with the server.
+func WithCache(dir string) Option {
+	return func(e *Embedder) {
+		e.options.Cache = dir
+	}
+}
+
+// Serves every request from the cache set with WithCache, fresh or not,
+// and fails with net.ErrCacheMiss for resources that are not cached.
+func WithOffline(offline bool) Option {
+	return func(e *Embedder) {
+		e.options.Offline = offline
+	}
+}
+
+func New(options ...Option) *Embedder {
+	e := &Embedder{
+		depth: 1,
+		options: *session.DefaultOptions(),
+	}
+
+	for i := 0; i < len(options); i++ {
+		options[i](e)
+	}
+
+	// shared by every request of the Embedder so connections are reused
+	e.options.Client = net.NewClient(&e.options)
+
+	return e
+}
+
+func (e *Embedder) newSession(source, origin string, body []byte, recurse int) *session.SessionConfig {
+	options := e.options
+	options.Summary = &session.Summary{}
+
+	return &session.SessionConfig {
+		Source:     source,
+		Origin:     origin,
+		Body:       body,
+		Recurse:    recurse,
+		Resources:  []Resource{},
+		Options:    &options,
+	}
+}
+
+// Reads a document and embeds its resources, resolving relative paths
+// against base, or against the origin of the Embedder if base is empty.
+func (e *Embedder) Embed(r io.Reader, base string) (*Result, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.EmbedBytes(body, base)
+}
+
+func (e *Embedder) EmbedBytes(body []byte, base string) (*Result, error) {
+	if base == "" {
+		base = e.origin
+	}
+
+	if base == "" {
+		return nil, &session.ConfigError{Reason: "missing parameter", Value: "origin"}
+	}
+
+	origin, err := session.NormalizeOrigin(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.depth < 1 {
+		return &Result{base, body, []Resource{}, []Failure{}, map[string]int{}, Connections{}}, nil
+	}
+
+	s := e.newSession(base, origin, body, e.depth)
+
+	err = parser.Parse(s)
+
+	return &Result{base, s.Body, s.Resources, s.Summary.Failures, s.Summary.Denials, s.Summary.Connections}, err
+}
+
+// Retrieves a document through the fetcher of the Embedder, returning its
+// body and the address it was eventually retrieved from after redirects.
+func (e *Embedder) Fetch(address string) ([]byte, string, error) {
+	f := e.options.Fetcher
+	if f == nil {
+		f = fetch.HTTP
+	}
+
+	s := e.newSession(address, "", []byte(""), 0)
+
+	// limits apply to the resources of a document, not to the document
+	s.Limits = session.Limits{}
+
+	body, _, location, err := fetch.Location(f, address, s)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return body, location, nil
+}
+
+// Retrieves a document and embeds its resources, resolving relative paths
+// against the origin of the Embedder, or against the address the document
+// was retrieved from if no origin is set.
+func (e *Embedder) EmbedURL(address string) (*Result, error) {
+	body, location, err := e.Fetch(address)
+	if err != nil {
+		return nil, err
+	}
+
+	base := e.origin
+	if base == "" {
+		base = location
+	}
+
+	return e.EmbedBytes(body, base)
+}
+
+// Encodes a single file as a data URL, name is only used to determine
+// the MIME type by file extension if it cannot be detected from body.
+func (e *Embedder) Encode(body []byte, name string) ([]byte, error) {
+	s := e.newSession(name, "", body, 0)
+
+	err := parser.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Body, nil
+}
@@ -0,0 +1,84 @@
+package fetch
+
+/*
+*	
+*	Local directory mirror
+*	
+*/
+
+import(
+	"os"
+	"mime"
+	"path"
+	"net/url"
+	"strings"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/buffermet/epoxy/net"
+	"github.com/buffermet/epoxy/session"
+)
+
+// Maps URL paths onto the files below Root. Both the layout written by
+// `wget --mirror` (Root/host/path) and a plain copy of the document root
+// (Root/path) are understood, directory paths resolve to index.html.
+// Files outside of Root are never read.
+type Dir struct {
+	Root string
+}
+
+func (d Dir) candidates(address string) []string {
+	u, err := url.Parse(address)
+	if err != nil || !isHostDir(u.Host) {
+		return []string{}
+	}
+
+	clean_path := path.Clean("/" + u.Path)
+	if clean_path == "/" || len(u.Path) > 0 && u.Path[len(u.Path) - 1] == '/' {
+		clean_path = path.Join(clean_path, "index.html")
+	}
+
+	paths := []string{}
+
+	if u.RawQuery != "" {
+		paths = append(paths, filepath.Join(d.Root, u.Host, filepath.FromSlash(clean_path + "?" + u.RawQuery)))
+	}
+
+	paths = append(paths, filepath.Join(d.Root, u.Host, filepath.FromSlash(clean_path)))
+	paths = append(paths, filepath.Join(d.Root, filepath.FromSlash(clean_path)))
+
+	within := []string{}
+	for i := 0; i < len(paths); i++ {
+		if net.WithinRoot(paths[i], d.Root) {
+			within = append(within, paths[i])
+		}
+	}
+
+	return within
+}
+
+// Returns true if host can name a directory below the root, which rules
+// out hosts such as ".." that documents can reference as //../file.
+func isHostDir(host string) bool {
+	return host != "" && host != "." && host != ".." && !strings.ContainsAny(host, "/\\")
+}
+
+func (d Dir) Fetch(address string, s *session.SessionConfig) ([]byte, string, error) {
+	candidates := d.candidates(address)
+
+	for i := 0; i < len(candidates); i++ {
+		info, err := os.Stat(candidates[i])
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		body, err := ioutil.ReadFile(candidates[i])
+		if err != nil {
+			return []byte(""), "", err
+		}
+
+		return body, mime.TypeByExtension(path.Ext(stripSearchAndHash(address))), nil
+	}
+
+	return []byte(""), "", &NotFoundError{address}
+}
@@ -0,0 +1,96 @@
+package fetch
+
+/*
+*	
+*	Fetcher implementations
+*	
+*	Resources can be retrieved over HTTP, from a local mirror of a site,
+*	from memory, or from several of those in order.
+*	
+*/
+
+import(
+	"errors"
+	"strings"
+
+	"github.com/buffermet/epoxy/net"
+	"github.com/buffermet/epoxy/session"
+)
+
+var (
+	ErrNotFound = errors.New("resource not found")
+
+	// Retrieves resources over the network using net.SendRequest.
+	HTTP session.Fetcher = httpFetcher{}
+)
+
+type httpFetcher struct{}
+
+func (httpFetcher) Fetch(address string, s *session.SessionConfig) ([]byte, string, error) {
+	return net.SendRequest(address, s)
+}
+
+func (httpFetcher) FetchLocation(address string, s *session.SessionConfig) ([]byte, string, string, error) {
+	return net.SendRequestLocation(address, s)
+}
+
+// Retrieves address through f, returning the address the body was eventually
+// retrieved from if f follows redirects, or address itself otherwise.
+func Location(f session.Fetcher, address string, s *session.SessionConfig) ([]byte, string, string, error) {
+	if location_fetcher, ok := f.(session.LocationFetcher); ok {
+		return location_fetcher.FetchLocation(address, s)
+	}
+
+	body, content_type, err := f.Fetch(address, s)
+
+	return body, content_type, address, err
+}
+
+// Returned by the Dir and Memory fetchers when they hold no copy of the
+// requested resource.
+type NotFoundError struct {
+	Address string
+}
+
+func (e *NotFoundError) Error() string {
+	return "no copy of " + e.Address
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound || target == net.ErrFetch
+}
+
+// Tries every fetcher in order and returns the first successful response,
+// or the error of the last fetcher if none of them succeeds.
+type Chain []session.Fetcher
+
+func (c Chain) Fetch(address string, s *session.SessionConfig) ([]byte, string, error) {
+	body, content_type, _, err := c.FetchLocation(address, s)
+
+	return body, content_type, err
+}
+
+func (c Chain) FetchLocation(address string, s *session.SessionConfig) ([]byte, string, string, error) {
+	err := error(&NotFoundError{address})
+
+	for i := 0; i < len(c); i++ {
+		var body []byte
+		var content_type, location string
+
+		body, content_type, location, err = Location(c[i], address, s)
+		if err == nil {
+			return body, content_type, location, nil
+		}
+	}
+
+	return []byte(""), "", address, err
+}
+
+// Strips the query string and fragment of an address.
+func stripSearchAndHash(address string) string {
+	if i := strings.IndexAny(address, "?#"); i != -1 {
+		return address[:i]
+	}
+
+	return address
+}
@@ -0,0 +1,37 @@
+package fetch
+
+/*
+*	
+*	In-memory resources
+*	
+*/
+
+import(
+	"mime"
+	"path"
+
+	"github.com/buffermet/epoxy/session"
+)
+
+type Entry struct {
+	Body []byte
+	ContentType string
+}
+
+// Answers requests from a fixed set of resources keyed by their absolute
+// address, entries without a ContentType are typed by file extension.
+type Memory map[string]Entry
+
+func (m Memory) Fetch(address string, s *session.SessionConfig) ([]byte, string, error) {
+	entry, ok := m[address]
+	if !ok {
+		return []byte(""), "", &NotFoundError{address}
+	}
+
+	content_type := entry.ContentType
+	if content_type == "" {
+		content_type = mime.TypeByExtension(path.Ext(stripSearchAndHash(address)))
+	}
+
+	return entry.Body, content_type, nil
+}
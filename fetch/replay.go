@@ -0,0 +1,245 @@
+package fetch
+
+/*
+*	
+*	Replay of recorded responses
+*	
+*	Answers requests from the responses stored in a WARC file or in a HAR
+*	file exported from the developer tools of a browser.
+*	
+*/
+
+import(
+	"io"
+	"os"
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"net/url"
+	"net/http"
+	"io/ioutil"
+	"encoding/json"
+	"path/filepath"
+	"encoding/base64"
+
+	"github.com/buffermet/epoxy/net"
+	"github.com/buffermet/epoxy/warc"
+	"github.com/buffermet/epoxy/session"
+)
+
+type replayEntry struct {
+	status int
+	status_text string
+	content_type string
+	location string
+	body []byte
+}
+
+// Answers requests from recorded responses, following recorded redirects.
+type Replay struct {
+	entries map[string]replayEntry
+}
+
+func NewReplay() *Replay {
+	return &Replay{map[string]replayEntry{}}
+}
+
+// Stores a response, a successful response is not replaced by a failed
+// one recorded for the same address.
+func (r *Replay) add(address string, entry replayEntry) {
+	if i := strings.Index(address, "#"); i != -1 {
+		address = address[:i]
+	}
+
+	if previous, ok := r.entries[address]; ok && previous.status < 400 && entry.status >= 400 {
+		return
+	}
+
+	r.entries[address] = entry
+}
+
+func (r *Replay) Fetch(address string, s *session.SessionConfig) ([]byte, string, error) {
+	body, content_type, _, err := r.FetchLocation(address, s)
+
+	return body, content_type, err
+}
+
+func (r *Replay) FetchLocation(address string, s *session.SessionConfig) ([]byte, string, string, error) {
+	for redirects := 0; redirects < 10; redirects++ {
+		if i := strings.Index(address, "#"); i != -1 {
+			address = address[:i]
+		}
+
+		entry, ok := r.entries[address]
+		if !ok {
+			return []byte(""), "", address, &NotFoundError{address}
+		}
+
+		if entry.status >= 300 && entry.status < 400 && entry.location != "" {
+			base, err := url.Parse(address)
+			if err != nil {
+				return []byte(""), "", address, &net.FetchError{URL: address, Attempts: 1, Err: err}
+			}
+
+			location, err := base.Parse(entry.location)
+			if err != nil {
+				return []byte(""), "", address, &net.FetchError{URL: address, Attempts: 1, Err: err}
+			}
+
+			address = location.String()
+			continue
+		}
+
+		if entry.status < 200 || entry.status > 299 {
+			return []byte(""), "", address, &net.StatusError{URL: address, StatusCode: entry.status, Status: strconv.Itoa(entry.status) + " " + entry.status_text}
+		}
+
+		return entry.body, entry.content_type, address, nil
+	}
+
+	return []byte(""), "", address, &NotFoundError{address}
+}
+
+// Reverses a Content-Encoding the recording tool kept in the stored body.
+func decodeBody(body []byte, encoding string) []byte {
+	if strings.TrimSpace(encoding) == "" {
+		return body
+	}
+
+	decoded, err := net.Decode(body, encoding, "", 0)
+	if err != nil {
+		return body
+	}
+
+	return decoded
+}
+
+// Adds the response and resource records of a WARC file to r.
+func (r *Replay) ReadWARC(reader io.Reader) error {
+	records, err := warc.NewReader(reader)
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := records.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch record.Type() {
+		case "resource":
+			r.add(record.TargetURI(), replayEntry{200, "OK", record.Header.Get("Content-Type"), "", record.Block})
+		case "response":
+			if !strings.HasPrefix(record.Header.Get("Content-Type"), "application/http") {
+				continue
+			}
+
+			res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(record.Block)), nil)
+			if err != nil {
+				continue
+			}
+
+			body, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+
+			r.add(record.TargetURI(), replayEntry {
+				res.StatusCode,
+				http.StatusText(res.StatusCode),
+				res.Header.Get("Content-Type"),
+				res.Header.Get("Location"),
+				decodeBody(body, res.Header.Get("Content-Encoding")),
+			})
+		}
+	}
+}
+
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				URL string `json:"url"`
+			} `json:"request"`
+			Response struct {
+				Status int `json:"status"`
+				StatusText string `json:"statusText"`
+				RedirectURL string `json:"redirectURL"`
+				Content struct {
+					Size int64 `json:"size"`
+					MimeType string `json:"mimeType"`
+					Text string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// Adds the entries of a HAR file to r. Requests that were blocked or
+// cancelled, which browsers record with status 0, and responses recorded
+// without their content are left out, so they are not found.
+func (r *Replay) ReadHAR(reader io.Reader) error {
+	var har harFile
+
+	err := json.NewDecoder(reader).Decode(&har)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(har.Log.Entries); i++ {
+		entry := har.Log.Entries[i]
+
+		if entry.Response.Status == 0 {
+			continue
+		}
+
+		// a size but no text means the content was not saved
+		if entry.Response.Content.Text == "" && entry.Response.Content.Size != 0 && entry.Response.RedirectURL == "" {
+			continue
+		}
+
+		body := []byte(entry.Response.Content.Text)
+		if entry.Response.Content.Encoding == "base64" {
+			body, err = base64.StdEncoding.DecodeString(entry.Response.Content.Text)
+			if err != nil {
+				continue
+			}
+		}
+
+		r.add(entry.Request.URL, replayEntry {
+			entry.Response.Status,
+			entry.Response.StatusText,
+			entry.Response.Content.MimeType,
+			entry.Response.RedirectURL,
+			body,
+		})
+	}
+
+	return nil
+}
+
+// Reads a HAR file if path ends in .har, or a WARC file otherwise.
+func OpenReplay(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := NewReplay()
+
+	if strings.EqualFold(filepath.Ext(path), ".har") {
+		err = r.ReadHAR(file)
+	} else {
+		err = r.ReadWARC(file)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,142 @@
+package fetch
+
+import(
+	"time"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/buffermet/epoxy/net"
+	"github.com/buffermet/epoxy/warc"
+	"github.com/buffermet/epoxy/session"
+)
+
+func exchange(address, status string, header string, body []byte) session.Exchange {
+	return session.Exchange {
+		URL:             address,
+		Date:            time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		RequestHeader:   []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+		ResponseHeader:  []byte("HTTP/1.1 " + status + "\r\n" + header + "\r\n"),
+		Body:            body,
+	}
+}
+
+func TestWARCRoundTrip(t *testing.T) {
+	root := session.Resource{Type: "text/html", Address: "https://example.com/blog/post.html", Body: []byte(`<img src="a.png">`)}
+
+	resources := []session.Resource {
+		{Type: "image/png", Address: "https://example.com/blog/a.png", Body: []byte("png")},
+		{Type: "text/css", Address: "https://example.com/mirrored.css", Body: []byte("body {}")},
+	}
+
+	exchanges := []session.Exchange {
+		exchange("https://example.com/old.png", "301 Moved Permanently", "Location: /blog/a.png\r\n", nil),
+		exchange("https://example.com/blog/a.png", "200 OK", "Content-Type: image/png\r\nContent-Length: 3\r\n", []byte("png")),
+		exchange("https://example.com/gone.png", "404 Not Found", "Content-Length: 0\r\n", nil),
+	}
+
+	for _, compress := range []bool{false, true} {
+		var archive bytes.Buffer
+
+		err := warc.Write(&archive, "test.warc", compress, root, resources, exchanges)
+		if err != nil {
+			t.Fatalf("Write() returned %v", err)
+		}
+
+		replay := NewReplay()
+
+		err = replay.ReadWARC(&archive)
+		if err != nil {
+			t.Fatalf("ReadWARC() returned %v", err)
+		}
+
+		tests := []struct {
+			address string
+			body string
+			content_type string
+			location string
+		}{
+			{"https://example.com/blog/post.html", `<img src="a.png">`, "text/html", "https://example.com/blog/post.html"},
+			{"https://example.com/blog/a.png#top", "png", "image/png", "https://example.com/blog/a.png"},
+			{"https://example.com/old.png", "png", "image/png", "https://example.com/blog/a.png"},
+			{"https://example.com/mirrored.css", "body {}", "text/css", "https://example.com/mirrored.css"},
+		}
+
+		for _, test := range tests {
+			body, content_type, location, err := replay.FetchLocation(test.address, nil)
+			if err != nil {
+				t.Errorf("FetchLocation(%q) returned %v", test.address, err)
+				continue
+			}
+
+			if string(body) != test.body || content_type != test.content_type || location != test.location {
+				t.Errorf("FetchLocation(%q) = %q, %q, %q", test.address, body, content_type, location)
+			}
+		}
+
+		_, _, _, err = replay.FetchLocation("https://example.com/gone.png", nil)
+
+		var status_err *net.StatusError
+		if !errors.As(err, &status_err) || status_err.StatusCode != 404 {
+			t.Errorf("FetchLocation() of a 404 returned %v", err)
+		}
+
+		_, _, _, err = replay.FetchLocation("https://example.com/missing.png", nil)
+		if !errors.As(err, new(*NotFoundError)) {
+			t.Errorf("FetchLocation() of a missing address returned %v", err)
+		}
+	}
+}
+
+func TestReadWARCCorrupt(t *testing.T) {
+	for _, length := range []string{"-1", "x", "99999999999999999999", "1099511627776", "100"} {
+		record := "WARC/1.1\r\nWARC-Type: resource\r\nWARC-Target-URI: https://example.com/\r\nContent-Length: " + length + "\r\n\r\nshort"
+
+		if NewReplay().ReadWARC(strings.NewReader(record)) == nil {
+			t.Errorf("ReadWARC() accepted a record with Content-Length %s", length)
+		}
+	}
+}
+
+func TestReadHAR(t *testing.T) {
+	har := `{"log": {"entries": [
+		{"request": {"url": "https://example.com/a.css"}, "response": {"status": 200, "content": {"size": 7, "mimeType": "text/css", "text": "body {}"}}},
+		{"request": {"url": "https://example.com/b.png"}, "response": {"status": 200, "content": {"size": 3, "mimeType": "image/png", "text": "cG5n", "encoding": "base64"}}},
+		{"request": {"url": "https://example.com/empty.txt"}, "response": {"status": 200, "content": {"size": 0, "mimeType": "text/plain"}}},
+		{"request": {"url": "https://example.com/blocked.js"}, "response": {"status": 0, "content": {"size": 0}}},
+		{"request": {"url": "https://example.com/unsaved.js"}, "response": {"status": 200, "content": {"size": 1024, "mimeType": "text/javascript"}}},
+		{"request": {"url": "https://example.com/old.css"}, "response": {"status": 302, "redirectURL": "/a.css", "content": {"size": 0}}}
+	]}}`
+
+	replay := NewReplay()
+
+	err := replay.ReadHAR(strings.NewReader(har))
+	if err != nil {
+		t.Fatalf("ReadHAR() returned %v", err)
+	}
+
+	tests := []struct {
+		address string
+		body string
+		found bool
+	}{
+		{"https://example.com/a.css", "body {}", true},
+		{"https://example.com/b.png", "png", true},
+		{"https://example.com/empty.txt", "", true},
+		{"https://example.com/blocked.js", "", false},
+		{"https://example.com/unsaved.js", "", false},
+		{"https://example.com/old.css", "body {}", true},
+		{"https://example.com/missing.js", "", false},
+	}
+
+	for _, test := range tests {
+		body, _, err := replay.Fetch(test.address, nil)
+
+		if test.found && (err != nil || string(body) != test.body) {
+			t.Errorf("Fetch(%q) = %q, %v, expected %q", test.address, body, err, test.body)
+		} else if !test.found && !errors.As(err, new(*NotFoundError)) {
+			t.Errorf("Fetch(%q) returned %v, expected a NotFoundError", test.address, err)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/buffermet/epoxy
+
+go 1.25.0
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/andybalholm/brotli v1.2.0
+	github.com/h2non/filetype v1.1.3
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/net v0.57.0
+	gopkg.in/yaml.v3 v3.0.1
+)
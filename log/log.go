@@ -7,6 +7,7 @@ package log
 */
 
 import (
+	"io"
 	"os"
 	"fmt"
 	"bufio"
@@ -21,38 +22,42 @@ var (
 	ON_GREEN  = "\x1b[0;42;37m"
 	ON_GREY   = "\x1b[0;40;37m"
 	ON_WHITE  = "\x1b[0;47;37m"
+
+	// Destination of every message, set to ioutil.Discard to silence
+	// the library.
+	Output io.Writer = os.Stdout
 )
 
 func Success(message string) {
-	fmt.Print(ON_GREEN + " " + RESET + " ", message, "\n")
+	fmt.Fprint(Output, ON_GREEN + " " + RESET + " ", message, "\n")
 }
 
 func Info(message string) {
-	fmt.Print(ON_GREY + " " + RESET + " ", message, "\n")
+	fmt.Fprint(Output, ON_GREY + " " + RESET + " ", message, "\n")
 }
 
 func Warn(message string) {
-	fmt.Print(ON_YELLOW + " " + RESET + " ", message, "\n")
+	fmt.Fprint(Output, ON_YELLOW + " " + RESET + " ", message, "\n")
 }
 
 func Error(message string) {
-	fmt.Print(ON_RED + " " + RESET + " ", message, "\n")
+	fmt.Fprint(Output, ON_RED + " " + RESET + " ", message, "\n")
 }
 
 func Fatal(message string) {
-	fmt.Print(ON_RED + " " + RESET + " ", message, "\n")
+	fmt.Fprint(Output, ON_RED + " " + RESET + " ", message, "\n")
 	Raw("")
 	os.Exit(0)
 }
 
 func Raw(message string) {
-	fmt.Print(message, "\n")
+	fmt.Fprint(Output, message, "\n")
 }
 
 func Prompt(message string) string {
 	reader := bufio.NewReader(os.Stdin)
 
-	fmt.Print(ON_WHITE + " " + RESET + " " + message + "\n" + ON_WHITE + " " + RESET + " ")
+	fmt.Fprint(Output, ON_WHITE + " " + RESET + " " + message + "\n" + ON_WHITE + " " + RESET + " ")
 
 	stdin, _ := reader.ReadString('\n')
     stdin = strings.Replace(stdin, "\n", "", -1)
@@ -0,0 +1,130 @@
+package mhtml
+
+/*
+*	
+*	MHTML archives
+*	
+*	Writes a document and its resources as an RFC 2557 multipart/related
+*	archive, in which resources are referenced by their Content-Location
+*	instead of being embedded as data URLs.
+*	
+*/
+
+import(
+	"io"
+	"time"
+	"strings"
+	"net/textproto"
+	"mime/multipart"
+	"encoding/base64"
+	"mime/quotedprintable"
+
+	"github.com/buffermet/epoxy/session"
+)
+
+func isText(mimetype string) bool {
+	return strings.HasPrefix(mimetype, "text/") ||
+	       strings.HasSuffix(mimetype, "+xml") ||
+	       strings.HasSuffix(mimetype, "/javascript") ||
+	       strings.HasSuffix(mimetype, "/x-javascript") ||
+	       strings.HasSuffix(mimetype, "/json")
+}
+
+func writePart(w *multipart.Writer, resource session.Resource) error {
+	mimetype := resource.Type
+	if mimetype == "" {
+		mimetype = "application/octet-stream"
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mimetype)
+	header.Set("Content-Location", resource.Address)
+
+	if isText(mimetype) {
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return err
+		}
+
+		encoder := quotedprintable.NewWriter(part)
+		if _, err = encoder.Write(resource.Body); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	}
+
+	header.Set("Content-Transfer-Encoding", "base64")
+
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	// 57 bytes encode to a line of 76 characters
+	for i := 0; i < len(resource.Body); i += 57 {
+		end := i + 57
+		if end > len(resource.Body) {
+			end = len(resource.Body)
+		}
+
+		_, err = io.WriteString(part, base64.StdEncoding.EncodeToString(resource.Body[i:end]) + "\r\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes root, followed by every resource that was retrieved, as an
+// archive. Resources are written once per address, placeholders for
+// resources that could not be retrieved are left out.
+func Write(w io.Writer, root session.Resource, resources []session.Resource) error {
+	mw := multipart.NewWriter(w)
+
+	mimetype := root.Type
+	if mimetype == "" {
+		mimetype = "text/html"
+	}
+	root.Type = mimetype
+
+	header := "From: <Saved by epoxy>\r\n" + 
+	          "Snapshot-Content-Location: " + root.Address + "\r\n" + 
+	          "Subject: " + root.Address + "\r\n" + 
+	          "Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" + 
+	          "MIME-Version: 1.0\r\n" + 
+	          "Content-Type: multipart/related;\r\n" + 
+	          "\ttype=\"" + mimetype + "\";\r\n" + 
+	          "\tboundary=\"" + mw.Boundary() + "\"\r\n" + 
+	          "\r\n"
+
+	_, err := io.WriteString(w, header)
+	if err != nil {
+		return err
+	}
+
+	err = writePart(mw, root)
+	if err != nil {
+		return err
+	}
+
+	written := map[string]bool{root.Address: true}
+
+	for i := 0; i < len(resources); i++ {
+		if resources[i].Placeholder || written[resources[i].Address] {
+			continue
+		}
+
+		written[resources[i].Address] = true
+
+		err = writePart(mw, resources[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
@@ -0,0 +1,272 @@
+package net
+
+/*
+*	
+*	Keeps responses in a directory to serve them again while they are
+*	fresh, following Cache-Control, Expires, ETag and Last-Modified.
+*	
+ */
+
+import (
+	"os"
+	"io"
+	"bytes"
+	"bufio"
+	"time"
+	"strconv"
+	"strings"
+	"net/http"
+	"io/ioutil"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+
+	"github.com/buffermet/epoxy/log"
+)
+
+// Wraps a transport and answers GET requests from the cache in dir while
+// the cached response is fresh, revalidating it once it is stale. Offline
+// it answers from the cache only, fresh or not.
+type cachingTransport struct {
+	transport http.RoundTripper
+	dir string
+	offline bool
+}
+
+// Body of a response answered from the cache without asking the server,
+// along with the time the response was stored.
+type cachedBody struct {
+	io.ReadCloser
+	stored time.Time
+}
+
+// Responses that are stored, permanent redirects included so offline runs
+// can follow them.
+var cacheableStatus = map[int]bool {
+	http.StatusOK:                 true,
+	http.StatusMovedPermanently:   true,
+	http.StatusPermanentRedirect:  true,
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		return t.transport.RoundTrip(req)
+	}
+
+	path := filepath.Join(t.dir, cacheKey(req.URL.String()))
+
+	cached, body, stored := readCached(path, req)
+
+	if t.offline {
+		if cached == nil {
+			return nil, &CacheMissError{req.URL.String()}
+		}
+
+		return cached, nil
+	}
+
+	if cached != nil && time.Since(stored) < freshness(cached.Header) {
+		return cached, nil
+	}
+
+	if cached != nil {
+		req = req.Clone(req.Context())
+
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	res, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+
+		// the 304 carries the current validators and freshness
+		for name, values := range res.Header {
+			if name != "Content-Length" && name != "Content-Encoding" && name != "Transfer-Encoding" {
+				cached.Header[name] = values
+			}
+		}
+
+		writeCached(path, req.URL.String(), cached, body)
+
+		// confirmed by the server just now
+		cached.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		return cached, nil
+	}
+
+	if !isStorable(res) {
+		return res, nil
+	}
+
+	body, err = readResponse(req, res)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	writeCached(path, req.URL.String(), res, body)
+
+	return res, nil
+}
+
+// Returns the name of the cache file of address.
+func cacheKey(address string) string {
+	sum := sha256.Sum256([]byte(address))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the directives of a Cache-Control header, lowercased, mapped to
+// their values.
+func cacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if parts[0] == "" {
+			continue
+		}
+
+		value := ""
+		if len(parts) == 2 {
+			value = strings.Trim(parts[1], `"`)
+		}
+
+		directives[strings.ToLower(parts[0])] = value
+	}
+
+	return directives
+}
+
+func isStorable(res *http.Response) bool {
+	if !cacheableStatus[res.StatusCode] || res.Header.Get("Vary") == "*" {
+		return false
+	}
+
+	_, no_store := cacheControl(res.Header)["no-store"]
+
+	return !no_store
+}
+
+// Returns how long a response stays fresh after it was received: max-age,
+// else until Expires, else a tenth of the time since Last-Modified as
+// browsers do, or 0 if it has to be revalidated every time.
+func freshness(header http.Header) time.Duration {
+	directives := cacheControl(header)
+
+	if _, ok := directives["no-cache"]; ok {
+		return 0
+	}
+
+	if max_age, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(max_age)
+		if err != nil {
+			return 0
+		}
+
+		age, _ := strconv.Atoi(header.Get("Age"))
+
+		return time.Duration(seconds - age) * time.Second
+	}
+
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return 0
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		expiry, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+
+		return expiry.Sub(date)
+	}
+
+	if modified, err := http.ParseTime(header.Get("Last-Modified")); err == nil && modified.Before(date) {
+		return date.Sub(modified) / 10
+	}
+
+	return 0
+}
+
+// Returns the response cached at path with its body, and when it was
+// stored, or nil if there is none.
+func readCached(path string, req *http.Request) (*http.Response, []byte, time.Time) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, time.Time{}
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, time.Time{}
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(f), req)
+	if err != nil {
+		return nil, nil, time.Time{}
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, nil, time.Time{}
+	}
+
+	res.Body = &cachedBody{ioutil.NopCloser(bytes.NewReader(body)), info.ModTime()}
+
+	return res, body, info.ModTime()
+}
+
+// Stores the response to address and its body at path by way of a
+// temporary file, so parallel runs never read a partial entry. Failures
+// only cost the entry.
+func writeCached(path, address string, res *http.Response, body []byte) {
+	header := res.Header.Clone()
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	var entry bytes.Buffer
+	entry.WriteString("HTTP/1.1 " + res.Status + "\r\n")
+	header.Write(&entry)
+	entry.WriteString("\r\n")
+	entry.Write(body)
+
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		log.Warn("cannot cache " + address + " (" + err.Error() + ")")
+		return
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err == nil {
+		_, err = tmp.Write(entry.Bytes())
+
+		if close_err := tmp.Close(); err == nil {
+			err = close_err
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+
+	if err != nil {
+		log.Warn("cannot cache " + address + " (" + err.Error() + ")")
+	}
+}
@@ -0,0 +1,43 @@
+package net
+
+import(
+	"time"
+	"testing"
+	"net/http"
+)
+
+func TestFreshness(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	format := func(t time.Time) string {
+		return t.Format(http.TimeFormat)
+	}
+
+	tests := []struct {
+		header map[string]string
+		freshness time.Duration
+	}{
+		{map[string]string{}, 0},
+		{map[string]string{"Cache-Control": "max-age=600"}, 600 * time.Second},
+		{map[string]string{"Cache-Control": "public, MAX-AGE=\"600\""}, 600 * time.Second},
+		{map[string]string{"Cache-Control": "max-age=600", "Age": "100"}, 500 * time.Second},
+		{map[string]string{"Cache-Control": "max-age=600, no-cache"}, 0},
+		{map[string]string{"Cache-Control": "max-age=soon"}, 0},
+		{map[string]string{"Cache-Control": "max-age=60", "Date": format(date), "Expires": format(date.Add(time.Hour))}, 60 * time.Second},
+		{map[string]string{"Date": format(date), "Expires": format(date.Add(time.Hour))}, time.Hour},
+		{map[string]string{"Date": format(date), "Expires": "0"}, 0},
+		{map[string]string{"Expires": format(date.Add(time.Hour))}, 0},
+		{map[string]string{"Date": format(date), "Last-Modified": format(date.Add(-10 * time.Hour))}, time.Hour},
+		{map[string]string{"Date": format(date), "Last-Modified": format(date.Add(time.Hour))}, 0},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		for name, value := range test.header {
+			header.Set(name, value)
+		}
+
+		if freshness := freshness(header); freshness != test.freshness {
+			t.Errorf("freshness(%v) = %v, expected %v", test.header, freshness, test.freshness)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package net
+
+/*
+*	
+*	Decodes compressed response bodies.
+*	
+ */
+
+import (
+	"io"
+	"bytes"
+	"bufio"
+	"strings"
+	"compress/gzip"
+	"compress/zlib"
+	"compress/flate"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings that response bodies can be decoded from.
+var decoders = map[string]func(io.Reader) (io.Reader, error) {
+	"gzip":      gunzip,
+	"x-gzip":    gunzip,
+	"deflate":   inflate,
+	"br":        func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	"zstd":      unzstd,
+	"identity":  func(r io.Reader) (io.Reader, error) { return r, nil },
+}
+
+func gunzip(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+// Decodes in the calling goroutine, so the decoder needs no Close.
+func unzstd(r io.Reader) (io.Reader, error) {
+	return zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+}
+
+// Servers send deflate either wrapped in zlib as the spec says, or raw.
+func inflate(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+
+	header, err := buffered.Peek(2)
+	if err == nil && header[0] & 0x0f == 8 && (uint16(header[0]) << 8 | uint16(header[1])) % 31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+
+	return flate.NewReader(buffered), nil
+}
+
+// Returns the codings of an Accept-Encoding header that responses can be
+// decoded from, in the same order.
+func acceptEncoding(list string) string {
+	accepted := []string{}
+
+	for _, coding := range strings.Split(list, ",") {
+		name := strings.ToLower(strings.TrimSpace(strings.SplitN(coding, ";", 2)[0]))
+		if _, ok := decoders[name]; ok {
+			accepted = append(accepted, strings.TrimSpace(coding))
+		}
+	}
+
+	return strings.Join(accepted, ", ")
+}
+
+// Wraps body in decoders for the codings of a Content-Encoding header, in
+// reverse order of application. Unknown codings leave body as it is.
+func decodeReader(body io.Reader, encoding string) (io.Reader, error) {
+	codings := strings.Split(encoding, ",")
+
+	for i := len(codings) - 1; i >= 0; i-- {
+		decoder, ok := decoders[strings.ToLower(strings.TrimSpace(codings[i]))]
+		if strings.TrimSpace(codings[i]) == "" {
+			continue
+		} else if !ok {
+			return body, nil
+		}
+
+		var err error
+
+		body, err = decoder(body)
+		if err != nil {
+			return body, err
+		}
+	}
+
+	return body, nil
+}
+
+// Content codings that can be recognized by the magic number of a body,
+// brotli has none.
+var magics = []struct {
+	coding string
+	magic []byte
+} {
+	{"gzip", []byte{0x1f, 0x8b, 0x08}},
+	{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// Returns the coding that body is compressed with, recognized by its magic
+// number, or "" if it doesn't look compressed.
+func DetectEncoding(body []byte) string {
+	for i := 0; i < len(magics); i++ {
+		if bytes.HasPrefix(body, magics[i].magic) {
+			return magics[i].coding
+		}
+	}
+
+	return ""
+}
+
+// Decodes the body of the resource at address from coding, as named in a
+// Content-Encoding header, reading up to limit bytes unless limit is 0.
+func Decode(body []byte, coding, address string, limit int64) ([]byte, error) {
+	reader, err := decodeReader(bytes.NewReader(body), coding)
+	if err != nil {
+		return nil, err
+	}
+
+	return readBody(reader, address, limit)
+}
@@ -0,0 +1,143 @@
+package net
+
+import(
+	"io"
+	"bytes"
+	"errors"
+	"testing"
+	"io/ioutil"
+	"compress/gzip"
+	"compress/zlib"
+	"compress/flate"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compresses body with coding.
+func encode(t *testing.T, body []byte, coding string) []byte {
+	var buffer bytes.Buffer
+	var w io.WriteCloser
+
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buffer)
+	case "deflate":
+		w = zlib.NewWriter(&buffer)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buffer, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buffer)
+	case "zstd":
+		var err error
+		w, err = zstd.NewWriter(&buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatalf("unknown coding %s", coding)
+	}
+
+	_, err := w.Write(body)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestDecodeReader(t *testing.T) {
+	body := bytes.Repeat([]byte("body { color: red; }\n"), 50)
+
+	tests := []struct {
+		encoding string
+		encoded []byte
+	}{
+		{"", body},
+		{"identity", body},
+		{"gzip", encode(t, body, "gzip")},
+		{"x-gzip", encode(t, body, "gzip")},
+		{"deflate", encode(t, body, "deflate")},
+		{"deflate", encode(t, body, "raw-deflate")},
+		{"br", encode(t, body, "br")},
+		{"zstd", encode(t, body, "zstd")},
+		{"GZIP", encode(t, body, "gzip")},
+		{"gzip, br", encode(t, encode(t, body, "gzip"), "br")},
+		{"zstd,gzip", encode(t, encode(t, body, "zstd"), "gzip")},
+	}
+
+	for _, test := range tests {
+		reader, err := decodeReader(bytes.NewReader(test.encoded), test.encoding)
+		if err != nil {
+			t.Errorf("decodeReader(%q) returned %v", test.encoding, err)
+			continue
+		}
+
+		decoded, err := ioutil.ReadAll(reader)
+		if err != nil || !bytes.Equal(decoded, body) {
+			t.Errorf("decodeReader(%q) decoded %d bytes, %v, expected %d bytes", test.encoding, len(decoded), err, len(body))
+		}
+	}
+
+	// unknown codings are left as they are
+	encoded := encode(t, body, "gzip")
+
+	reader, err := decodeReader(bytes.NewReader(encoded), "gzip, compress")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, _ := ioutil.ReadAll(reader)
+	if !bytes.Equal(decoded, encoded) {
+		t.Errorf("decodeReader() decoded a body with an unknown coding")
+	}
+
+	_, err = decodeReader(bytes.NewReader(body), "gzip")
+	if err == nil {
+		t.Errorf("decodeReader() accepted a body that isn't gzip")
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	body := []byte("<html></html>")
+
+	tests := []struct {
+		body []byte
+		coding string
+	}{
+		{body, ""},
+		{[]byte{}, ""},
+		{[]byte{0x1f, 0x8b}, ""},
+		{encode(t, body, "gzip"), "gzip"},
+		{encode(t, body, "zstd"), "zstd"},
+		{encode(t, body, "br"), ""},
+		{encode(t, body, "deflate"), ""},
+	}
+
+	for _, test := range tests {
+		if coding := DetectEncoding(test.body); coding != test.coding {
+			t.Errorf("DetectEncoding(% x) = %q, expected %q", test.body[:min(len(test.body), 4)], coding, test.coding)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	body := bytes.Repeat([]byte("a"), 1000)
+	encoded := encode(t, body, "gzip")
+
+	decoded, err := Decode(encoded, "gzip", "https://example.com/a.txt", 0)
+	if err != nil || !bytes.Equal(decoded, body) {
+		t.Errorf("Decode() = %d bytes, %v", len(decoded), err)
+	}
+
+	// the limit applies to the decoded body
+	_, err = Decode(encoded, "gzip", "https://example.com/a.txt", 100)
+
+	var size_err *SizeError
+	if !errors.As(err, &size_err) {
+		t.Errorf("Decode() with a limit returned %v, expected a SizeError", err)
+	}
+}
@@ -0,0 +1,87 @@
+package net
+
+/*
+*	
+*	Errors returned by SendRequest.
+*	
+ */
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	ErrFetch = errors.New("cannot retrieve resource")
+	ErrOutsideRoot = errors.New("path is outside of the root directory")
+	ErrTooLarge = errors.New("resource exceeds the size limit")
+	ErrCacheMiss = errors.New("resource is not cached")
+)
+
+// Returned when a request cannot be built, sent or read.
+type FetchError struct {
+	URL string
+	Attempts int
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	str := "cannot retrieve resource at " + e.URL
+	if e.Attempts > 1 {
+		str += " after " + strconv.Itoa(e.Attempts) + " attempts"
+	}
+
+	return str + " (" + e.Err.Error() + ")"
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+func (e *FetchError) Is(target error) bool {
+	return target == ErrFetch
+}
+
+// Returned when the server answers with a status outside of the 2xx
+// range, so error pages are never mistaken for the resource.
+type StatusError struct {
+	URL string
+	StatusCode int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected status " + e.Status + " for " + e.URL
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrFetch
+}
+
+// Returned when a resource exceeds a size limit, the download is aborted
+// as soon as the limit is reached.
+type SizeError struct {
+	URL string
+	Limit int64
+}
+
+func (e *SizeError) Error() string {
+	return "resource at " + e.URL + " exceeds the size limit of " + strconv.FormatInt(e.Limit, 10) + " bytes"
+}
+
+func (e *SizeError) Is(target error) bool {
+	return target == ErrTooLarge
+}
+
+// Returned in offline mode for resources that are not in the cache.
+type CacheMissError struct {
+	URL string
+}
+
+func (e *CacheMissError) Error() string {
+	return "no cached copy of " + e.URL + " to use offline"
+}
+
+func (e *CacheMissError) Is(target error) bool {
+	return target == ErrCacheMiss || target == ErrFetch
+}
@@ -0,0 +1,60 @@
+package net
+
+/*
+*	
+*	Reads resources addressed by file:// URLs.
+*	
+ */
+
+import (
+	"os"
+	"mime"
+	"strings"
+	"net/url"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/buffermet/epoxy/session"
+)
+
+// Reports whether path, after resolving symbolic links, lies within root.
+func WithinRoot(path, root string) bool {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".." + string(filepath.Separator))
+}
+
+func readFile(address string, s *session.SessionConfig) ([]byte, string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return []byte(""), "", &FetchError{address, 1, err}
+	}
+
+	path := filepath.Clean(filepath.FromSlash(u.Path))
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "index.html")
+	}
+
+	// checked on the final path, as index.html can be a link out of root
+	if s.Root != "" && !WithinRoot(path, s.Root) {
+		return []byte(""), "", &FetchError{address, 1, ErrOutsideRoot}
+	}
+
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []byte(""), "", &FetchError{address, 1, err}
+	}
+
+	return body, mime.TypeByExtension(filepath.Ext(path)), nil
+}
@@ -7,42 +7,314 @@ package net
  */
 
 import (
-	"io/ioutil"
-	"net/http"
+	"io"
+	"context"
+	"time"
+	"errors"
+	"strconv"
 	"strings"
+	"math"
+	"net/http"
+	"math/rand"
+	"net/http/httptrace"
+	"io/ioutil"
 
 	"github.com/buffermet/epoxy/log"
 	"github.com/buffermet/epoxy/session"
 )
 
 var (
-	UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/60.0.3112.113 Safari/537.36"
+	// sent when the session has no browser profile
+	UserAgent = session.Browsers["chrome"].UserAgent
+	AcceptEncoding = "gzip, deflate, br, zstd"
 )
 
-func SendRequest(url string, s *session.SessionConfig) ([]byte, string) {
-	client := &http.Client{}
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
 
-	req, err := http.NewRequest("GET", url, strings.NewReader(""))
-	if err != nil {
-		log.Error("malformed request packet for " + url + " (" + err.Error() + ")")
-		return []byte(""), ""
+// Returns the delay requested by a Retry-After header, which is either
+// a number of seconds or an HTTP date, or 0 if there is none.
+func retryAfter(res *http.Response) time.Duration {
+	value := strings.TrimSpace(res.Header.Get("Retry-After"))
+	if value == "" {
+		return 0
 	}
 
-	req.Header.Set("User-Agent", UserAgent)
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
+
+// Returns the exponential backoff to wait after the given failed attempt,
+// with part of it randomized so parallel requests don't retry in lockstep.
+func backoff(policy session.RetryPolicy, attempt int) time.Duration {
+	delay := policy.Delay
+	for i := 1; i < attempt && delay < math.MaxInt64 / 2; i++ {
+		if policy.MaxDelay > 0 && delay >= policy.MaxDelay {
+			break
+		}
+
+		delay *= 2
+	}
+
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	if policy.Jitter > 0 && delay > 0 {
+		spread := time.Duration(float64(delay) * policy.Jitter)
+		if spread > 0 {
+			delay = delay - spread + time.Duration(rand.Int63n(int64(spread) + 1))
+		}
+	}
+
+	return delay
+}
+
+// Reads body up to limit bytes, or completely if limit is 0, and returns a
+// SizeError as soon as the limit is exceeded.
+func readBody(body io.Reader, url string, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return ioutil.ReadAll(body)
+	}
 
-	res, err := client.Do(req)
+	data, err := ioutil.ReadAll(io.LimitReader(body, limit + 1))
 	if err != nil {
-		log.Error("cannot retrieve resource at " + url + " (" + err.Error() + ")")
-		return []byte(""), ""
+		return data, err
+	}
+
+	if int64(len(data)) > limit {
+		return nil, &SizeError{url, limit}
 	}
 
+	return data, nil
+}
+
+// Returns the size limit for a response by its Content-Type header.
+func responseLimit(res *http.Response, s *session.SessionConfig) int64 {
+	return s.MaxSize(strings.SplitN(res.Header.Get("Content-Type"), ";", 2)[0])
+}
+
+// Reads the body of the response to req, up to the size limit of the
+// session the request is sent for if it is successful.
+func readResponse(req *http.Request, res *http.Response) ([]byte, error) {
 	defer res.Body.Close()
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		log.Error("cannot read body of response from " + url + " (" + err.Error() + ")")
-		return []byte(""), ""
+	limit := int64(0)
+	if s, ok := req.Context().Value(sessionKey{}).(*session.SessionConfig); ok && res.StatusCode >= 200 && res.StatusCode <= 299 {
+		limit = responseLimit(res, s)
+	}
+
+	if limit > 0 && res.ContentLength > limit {
+		return nil, &SizeError{req.URL.String(), limit}
+	}
+
+	return readBody(res.Body, req.URL.String(), limit)
+}
+
+// Context key of the session a request is sent for.
+type sessionKey struct{}
+
+// Returns a client that sends requests through the transport of o, or
+// session.DefaultTransport, by way of its cache directory if it has one,
+// and keeps cookies in its jar. The requests of
+// a run share one client so they can reuse connections.
+func NewClient(o *session.Options) *http.Client {
+	transport := o.Transport
+	if transport == nil {
+		transport = session.DefaultTransport
+	}
+
+	if o.Cache != "" {
+		transport = &cachingTransport{transport, o.Cache, o.Offline}
+	}
+
+	if o.Recorder != nil {
+		transport = &recordingTransport{transport, o.Recorder}
+	}
+
+	return &http.Client{Transport: transport, Jar: o.Jar}
+}
+
+// Sets the User-Agent, Accept, Accept-Language and Accept-Encoding headers
+// of the browser profile of s for a request to url, with only the codings
+// that responses can be decoded from.
+func setBrowserHeaders(req *http.Request, url string, s *session.SessionConfig) {
+	browser, ok := session.Browsers[s.Browser]
+	if !ok {
+		req.Header.Set("User-Agent", UserAgent)
+		req.Header.Set("Accept-Encoding", AcceptEncoding)
+	} else {
+		req.Header.Set("User-Agent", browser.UserAgent)
+		req.Header.Set("Accept", browser.AcceptHeader(s.Destination(url)))
+		req.Header.Set("Accept-Language", browser.Language)
+		req.Header.Set("Accept-Encoding", acceptEncoding(browser.Encoding))
+	}
+
+	if s.UserAgent != "" {
+		req.Header.Set("User-Agent", s.UserAgent)
+	}
+}
+
+func SendRequest(url string, s *session.SessionConfig) ([]byte, string, error) {
+	body, content_type, _, err := SendRequestLocation(url, s)
+
+	return body, content_type, err
+}
+
+// Same as SendRequest, but also returns the URL the response was eventually
+// retrieved from after following redirects.
+func SendRequestLocation(url string, s *session.SessionConfig) ([]byte, string, string, error) {
+	if strings.HasPrefix(strings.ToLower(url), "file://") {
+		body, content_type, err := readFile(url, s)
+		return body, content_type, url, err
+	}
+
+	client := s.Client
+	if client == nil {
+		client = NewClient(s.Options)
+	}
+
+	// counts whether requests reuse a connection, and hands the session to
+	// the recording transport for size limits
+	trace := &httptrace.ClientTrace {
+		GotConn: func(info httptrace.GotConnInfo) {
+			if s.Summary != nil {
+				s.Summary.AddConnection(info.Reused)
+			}
+		},
+	}
+	ctx := httptrace.WithClientTrace(context.WithValue(context.Background(), sessionKey{}, s), trace)
+
+	attempts := s.Retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return []byte(""), "", url, &FetchError{url, attempt, err}
+		}
+
+		setBrowserHeaders(req, url, s)
+
+		if referer := s.Referer(url); referer != "" {
+			req.Header.Set("Referer", referer)
+		}
+
+		// given headers replace the defaults above
+		for name, values := range s.Headers {
+			name = http.CanonicalHeaderKey(name)
+
+			if name == "Host" && len(values) > 0 {
+				req.Host = values[0]
+				continue
+			}
+
+			req.Header[name] = values
+		}
+
+		tries := "attempt " + strconv.Itoa(attempt) + "/" + strconv.Itoa(attempts)
+
+		res, err := client.Do(req)
+
+		// retrying won't make it any smaller
+		var size_err *SizeError
+		if errors.As(err, &size_err) {
+			return []byte(""), "", url, size_err
+		}
+
+		// nor put it in the cache
+		var miss_err *CacheMissError
+		if errors.As(err, &miss_err) {
+			return []byte(""), "", url, miss_err
+		}
+
+		if err != nil {
+			if attempt < attempts {
+				delay := backoff(s.Retry, attempt)
+				log.Warn(tries + " for " + url + " failed (" + err.Error() + "), retrying in " + delay.String() + " ...")
+				time.Sleep(delay)
+				continue
+			}
+
+			return []byte(""), "", url, &FetchError{url, attempt, err}
+		}
+
+		if res.ProtoMajor == 2 && s.Summary != nil {
+			s.Summary.AddHTTP2()
+		}
+
+		if isRetryableStatus(res.StatusCode) && attempt < attempts {
+			delay := backoff(s.Retry, attempt)
+			if requested := retryAfter(res); requested > 0 {
+				delay = requested
+				if s.Retry.MaxDelay > 0 && delay > s.Retry.MaxDelay {
+					delay = s.Retry.MaxDelay
+				}
+			}
+
+			ioutil.ReadAll(res.Body)
+			res.Body.Close()
+
+			log.Warn(tries + " for " + url + " failed (" + res.Status + "), retrying in " + delay.String() + " ...")
+			time.Sleep(delay)
+			continue
+		}
+
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			ioutil.ReadAll(res.Body)
+			res.Body.Close()
+
+			return []byte(""), "", url, &StatusError{url, res.StatusCode, res.Status}
+		}
+
+		limit := responseLimit(res, s)
+
+		if limit > 0 && res.ContentLength > limit {
+			res.Body.Close()
+			return []byte(""), "", url, &SizeError{url, limit}
+		}
+
+		var reader io.Reader = res.Body
+		if !res.Uncompressed {
+			reader, err = decodeReader(res.Body, res.Header.Get("Content-Encoding"))
+		}
+
+		body := []byte("")
+		if err == nil {
+			body, err = readBody(reader, url, limit)
+		}
+		res.Body.Close()
+		if errors.As(err, &size_err) {
+			return []byte(""), "", url, size_err
+		} else if err != nil {
+			if attempt < attempts {
+				delay := backoff(s.Retry, attempt)
+				log.Warn(tries + " for " + url + " failed (" + err.Error() + "), retrying in " + delay.String() + " ...")
+				time.Sleep(delay)
+				continue
+			}
+
+			return []byte(""), "", url, &FetchError{url, attempt, err}
+		}
+
+		if attempt > 1 {
+			log.Info("retrieved " + url + " on " + tries)
+		}
+
+		return body, res.Header.Get("Content-Type"), res.Request.URL.String(), nil
 	}
 
-	return body, res.Header.Get("Content-Type")
+	return []byte(""), "", url, nil
 }
@@ -0,0 +1,144 @@
+package net
+
+import(
+	"time"
+	"errors"
+	"testing"
+	"net/http"
+	"sync/atomic"
+	"net/http/httptest"
+
+	"github.com/buffermet/epoxy/session"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		max_delay time.Duration
+		attempt int
+		expected time.Duration
+	}{
+		{time.Second, 0, 1, time.Second},
+		{time.Second, 0, 2, 2 * time.Second},
+		{time.Second, 0, 4, 8 * time.Second},
+		{time.Second, 0, 100, time.Second << 33},
+		{time.Second, 30 * time.Second, 4, 8 * time.Second},
+		{time.Second, 30 * time.Second, 6, 30 * time.Second},
+		{time.Second, 30 * time.Second, 100, 30 * time.Second},
+		{time.Minute, 30 * time.Second, 1, 30 * time.Second},
+		{0, 30 * time.Second, 3, 0},
+	}
+
+	for _, test := range tests {
+		policy := session.RetryPolicy{Attempts: 3, Delay: test.delay, MaxDelay: test.max_delay}
+
+		if delay := backoff(policy, test.attempt); delay != test.expected {
+			t.Errorf("delay %s, max %s, attempt %d: backoff = %s, expected %s", test.delay, test.max_delay, test.attempt, delay, test.expected)
+		}
+	}
+
+	policy := session.RetryPolicy{Attempts: 3, Delay: time.Second, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		if delay := backoff(policy, 3); delay < 2 * time.Second || delay > 4 * time.Second {
+			t.Fatalf("jitter 0.5, attempt 3: backoff = %s, expected between 2s and 4s", delay)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		value string
+		min time.Duration
+		max time.Duration
+	}{
+		{"", 0, 0},
+		{"120", 120 * time.Second, 120 * time.Second},
+		{" 5 ", 5 * time.Second, 5 * time.Second},
+		{"0", 0, 0},
+		{"-5", 0, 0},
+		{"soon", 0, 0},
+		{now.Add(time.Hour).UTC().Format(http.TimeFormat), 58 * time.Minute, time.Hour},
+		{now.Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+	}
+
+	for _, test := range tests {
+		res := &http.Response{Header: http.Header{}}
+		if test.value != "" {
+			res.Header.Set("Retry-After", test.value)
+		}
+
+		if delay := retryAfter(res); delay < test.min || delay > test.max {
+			t.Errorf("Retry-After %q: retryAfter = %s, expected between %s and %s", test.value, delay, test.min, test.max)
+		}
+	}
+}
+
+func TestSendRequestLocationRetry(t *testing.T) {
+	tests := []struct {
+		statuses []int
+		attempts int
+		requests int32
+		status int
+	}{
+		{[]int{200}, 3, 1, 0},
+		{[]int{503, 503, 200}, 3, 3, 0},
+		{[]int{429, 200}, 3, 2, 0},
+		{[]int{503, 503, 503}, 3, 3, 503},
+		{[]int{503, 200}, 1, 1, 503},
+		{[]int{404, 200}, 3, 1, 404},
+		{[]int{403}, 3, 1, 403},
+		{[]int{204}, 3, 1, 0},
+	}
+
+	for _, test := range tests {
+		var requests int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := int(atomic.AddInt32(&requests, 1))
+			status := test.statuses[len(test.statuses) - 1]
+			if n <= len(test.statuses) {
+				status = test.statuses[n - 1]
+			}
+
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "60")
+			}
+
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(status)
+			w.Write([]byte("body"))
+		}))
+
+		options := session.DefaultOptions()
+		// Retry-After is capped by MaxDelay
+		options.Retry = session.RetryPolicy{Attempts: test.attempts, Delay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+		s := &session.SessionConfig{Options: options}
+
+		body, content_type, location, err := SendRequestLocation(server.URL + "/a.txt", s)
+		server.Close()
+
+		if requests != test.requests {
+			t.Errorf("%v: %d requests, expected %d", test.statuses, requests, test.requests)
+		}
+
+		if test.status == 0 {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", test.statuses, err)
+			} else if location != server.URL + "/a.txt" || content_type != "text/plain" {
+				t.Errorf("%v: location %s, content type %s", test.statuses, location, content_type)
+			}
+			continue
+		}
+
+		var status_err *StatusError
+		if !errors.As(err, &status_err) || status_err.StatusCode != test.status || !errors.Is(err, ErrFetch) {
+			t.Errorf("%v: err = %v, expected status %d", test.statuses, err, test.status)
+		}
+		if len(body) != 0 {
+			t.Errorf("%v: body = %q, expected none", test.statuses, body)
+		}
+	}
+}
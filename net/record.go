@@ -0,0 +1,85 @@
+package net
+
+/*
+*	
+*	Records HTTP exchanges for archiving.
+*	
+ */
+
+import (
+	"time"
+	"bytes"
+	"strconv"
+	"net/http"
+	"io/ioutil"
+
+	"github.com/buffermet/epoxy/session"
+)
+
+// Wraps a transport and hands every exchange that passes through it,
+// including redirects and failed attempts, to a session.Recorder.
+type recordingTransport struct {
+	transport http.RoundTripper
+	recorder *session.Recorder
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	date := time.Now()
+
+	res, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	duration := time.Since(date)
+
+	// recorded as captured when it was stored, not as a live response
+	cached, from_cache := res.Body.(*cachedBody)
+	if from_cache {
+		date = cached.stored
+		duration = 0
+	}
+
+	body, err := readResponse(req, res)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	var request_header bytes.Buffer
+	request_header.WriteString(req.Method + " " + req.URL.RequestURI() + " HTTP/1.1\r\n")
+	request_header.WriteString("Host: " + host + "\r\n")
+	req.Header.Write(&request_header)
+	request_header.WriteString("\r\n")
+
+	// the body is stored as read, without transfer encoding and possibly
+	// decompressed by the transport, so its length replaces the one sent
+	header := res.Header.Clone()
+	if res.Uncompressed {
+		header.Del("Content-Encoding")
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	var response_header bytes.Buffer
+	response_header.WriteString("HTTP/1.1 " + res.Status + "\r\n")
+	header.Write(&response_header)
+	response_header.WriteString("\r\n")
+
+	t.recorder.Add(session.Exchange {
+		URL:             req.URL.String(),
+		Date:            date,
+		Duration:        duration,
+		RequestHeader:   request_header.Bytes(),
+		ResponseHeader:  response_header.Bytes(),
+		Body:            body,
+		Cached:          from_cache,
+	})
+
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package parser
+
+/*
+*	
+*	Errors returned by Parse and DecodeDataURL.
+*	
+*/
+
+import(
+	"errors"
+)
+
+var (
+	ErrResolve = errors.New("cannot resolve path")
+	ErrDecode = errors.New("cannot determine file type")
+	ErrDataURL = errors.New("cannot decode data URL")
+)
+
+// Returned when a path found in a document cannot be turned into an
+// absolute URL using the origin of that document.
+type ResolveError struct {
+	Path string
+	Origin string
+	Reason string
+}
+
+func (e *ResolveError) Error() string {
+	return "cannot resolve " + e.Path + " against " + e.Origin + " (" + e.Reason + ")"
+}
+
+func (e *ResolveError) Is(target error) bool {
+	return target == ErrResolve
+}
+
+// Returned when the file type of a source cannot be determined from its
+// contents nor from its file extension.
+type DecodeError struct {
+	Source string
+}
+
+func (e *DecodeError) Error() string {
+	return "cannot determine file type of " + e.Source
+}
+
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrDecode
+}
+
+// Returned when a data URL is malformed.
+type DataURLError struct {
+	Reason string
+}
+
+func (e *DataURLError) Error() string {
+	return "cannot decode data URL (" + e.Reason + ")"
+}
+
+func (e *DataURLError) Is(target error) bool {
+	return target == ErrDataURL
+}
@@ -0,0 +1,114 @@
+package parser
+
+/*
+*	
+*	Data URL extraction
+*	
+*	Finds and decodes the data URLs that Parse embeds, so resources can be
+*	taken back out of a document.
+*	
+*/
+
+import(
+	"regexp"
+	"strings"
+	"net/url"
+	"encoding/base64"
+)
+
+var (
+	selectorDataURLBase64                      = regexp.MustCompile(`(?i);base64$`)
+	selectorWhitespace                         = regexp.MustCompile(`\s+`)
+)
+
+// Returns the data URLs found in the places resources are embedded in by
+// Parse, in order of appearance and without duplicates.
+func FindDataURLs(body []byte) []string {
+	data_urls := []string{}
+	found := map[string]bool{}
+
+	for _, selector := range []*regexp.Regexp{
+		selectorHtmlSourceAttributeStrictValueMem,
+		selectorHtmlContentAttributeStrictValueMem,
+		selectorHtmlHrefAttributeStrictValueMem,
+		selectorHtmlUrlAttributeStrictValueMem,
+	} {
+		matches := selector.FindAllStringSubmatch(string(body), -1)
+
+		for i := 0; i < len(matches); i++ {
+			if !strings.HasPrefix(strings.ToLower(matches[i][1]), "data:") || found[matches[i][1]] {
+				continue
+			}
+
+			found[matches[i][1]] = true
+			data_urls = append(data_urls, matches[i][1])
+		}
+	}
+
+	return data_urls
+}
+
+// Returns the MIME type and the payload of a base64 or percent-encoded
+// data URL, the type defaults to text/plain as per RFC 2397.
+func DecodeDataURL(data_url string) (string, []byte, error) {
+	if !strings.HasPrefix(strings.ToLower(data_url), "data:") {
+		return "", nil, &DataURLError{"missing data: scheme"}
+	}
+
+	parts := strings.SplitN(data_url[len("data:"):], ",", 2)
+	if len(parts) != 2 {
+		return "", nil, &DataURLError{"missing comma"}
+	}
+
+	mimetype := strings.TrimSpace(selectorSemiColonAndRest.ReplaceAllString(parts[0], ""))
+	if mimetype == "" {
+		mimetype = "text/plain"
+	}
+
+	payload, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return "", nil, &DataURLError{"invalid percent-encoding"}
+	}
+
+	if selectorDataURLBase64.FindString(parts[0]) == "" {
+		return strings.ToLower(mimetype), []byte(payload), nil
+	}
+
+	payload = strings.TrimRight(selectorWhitespace.ReplaceAllString(payload, ""), "=")
+
+	body, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil {
+		body, err = base64.RawURLEncoding.DecodeString(payload)
+	}
+	if err != nil {
+		return "", nil, &DataURLError{"invalid base64"}
+	}
+
+	return strings.ToLower(mimetype), body, nil
+}
+
+// Replaces the data URLs in replacements with the corresponding value, in
+// the places they are found by FindDataURLs.
+func ReplaceDataURLs(body []byte, replacements map[string]string) []byte {
+	replaced := string(body)
+
+	for _, selector := range []*regexp.Regexp{
+		selectorHtmlSourceAttributeStrictValueMem,
+		selectorHtmlContentAttributeStrictValueMem,
+		selectorHtmlHrefAttributeStrictValueMem,
+		selectorHtmlUrlAttributeStrictValueMem,
+	} {
+		replaced = selector.ReplaceAllStringFunc(replaced, func(match string) string {
+			data_url := selector.FindStringSubmatch(match)[1]
+
+			replacement, ok := replacements[data_url]
+			if !ok {
+				return match
+			}
+
+			return strings.Replace(match, data_url, replacement, 1)
+		})
+	}
+
+	return []byte(replaced)
+}
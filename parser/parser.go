@@ -9,11 +9,12 @@ package parser
 */
 
 import(
-	"sync"
 	"mime"
+	"errors"
 	"regexp"
 	"strings"
 	"strconv"
+	"path/filepath"
 	"encoding/base64"
 
 	"github.com/h2non/filetype"
@@ -24,6 +25,7 @@ import(
 
 var (
 	selectorContentTypeCssHtmlSvg              = regexp.MustCompile(`(?:text/(?:css|html)|image/svg\+xml)`)
+	selectorContentTypeCompressed              = regexp.MustCompile(`(?i)(?:gzip|zstd|compress|zip|octet-stream)`)
 	selectorHtmlContentAttribute               = regexp.MustCompile(`(?i)content=["']([^"']*)["']`)
 	selectorHtmlContentAttributeStrictValue    = regexp.MustCompile(`(?i)content=["'][^"']+["']`)
 	selectorHtmlContentAttributeStrictValueMem = regexp.MustCompile(`(?i)content=["']([^"']+)["']`)
@@ -44,7 +46,8 @@ var (
 	selectorUriPathDotDotSlash                 = regexp.MustCompile(`[.][.]/`)
 	selectorUriPathStartingWithDot             = regexp.MustCompile(`^[.]`)
 	selectorUriScheme                          = regexp.MustCompile(`(?i)^[a-z]+:`)
-	selectorUriSchemeAndHost                   = regexp.MustCompile(`(?i)^http[s]?://[^/]+`)
+	selectorUriSchemeAndHost                   = regexp.MustCompile(`(?i)^(?:http[s]?://[^/]+|file://)`)
+	selectorUriSchemeFile                      = regexp.MustCompile(`(?i)^file://`)
 	selectorUriSchemeCurrent                   = regexp.MustCompile(`^//`)
 	selectorUriSchemeDataOrJavaScript          = regexp.MustCompile(`(?i)^(?:data:|javascript:|#)`)
 	selectorUriStartOfPath                     = regexp.MustCompile(`^/`)
@@ -52,18 +55,23 @@ var (
 	selectorUriSearchOrHash                    = regexp.MustCompile(`(?:\?|#).*$`)
 )
 
-func containsString(slice *[]string, str string) bool {
-	for i := 0; i < len(*slice); i++ {
-		if strings.EqualFold((*slice)[i], str) {
-			return true
-		}
-	}
-	return false
-}
-
-func pathToURL(path, origin string) string {
+// Resolves path against the origin of the document it was found in. For
+// file:// origins below root, root takes the place of the host, so paths
+// starting with a slash resolve against it and ../ cannot leave it.
+// Only file:// documents may reference file:// URLs, so a remote page
+// cannot read local files.
+func pathToURL(path, origin, root string) (string, error) {
 	origin_host := selectorUriSchemeAndHost.FindString(origin)
 	origin_path := selectorUriSchemeAndHost.ReplaceAllString(origin, "")
+
+	if root != "" && selectorUriSchemeFile.FindString(origin_host) != "" {
+		root_path := strings.TrimSuffix(filepath.ToSlash(root), "/")
+
+		if strings.HasPrefix(origin_path, root_path + "/") {
+			origin_host = origin_host + root_path
+			origin_path = strings.TrimPrefix(origin_path, root_path)
+		}
+	}
 	origin_path = selectorUriLeadingUpToPath.ReplaceAllString(origin_path, "")
 	origin_scheme := selectorUriScheme.FindString(origin)
 
@@ -78,9 +86,9 @@ func pathToURL(path, origin string) string {
 					count := len(selectorUriPathDotDotSlash.FindAllString(path, -1))
 
 					if count == 0 {
-						log.Error("invalid path detected: " + path)
+						return "", &ResolveError{path, origin, "invalid path"}
 					} else if len(selectorUriNotSlash.FindAllString(origin_path, -1)) < count {
-						log.Error("unable to move out of directory: " + path + " (origin path is not long enough)")
+						return "", &ResolveError{path, origin, "origin path is not long enough"}
 					} else {
 						stripped_path := origin_path
 						for i := 0; i < count; i++ {
@@ -100,13 +108,33 @@ func pathToURL(path, origin string) string {
 		url = origin_scheme + path
 	}
 
-	return url
+	if selectorUriSchemeFile.FindString(url) != "" && selectorUriSchemeFile.FindString(origin) == "" {
+		return "", &ResolveError{path, origin, "file URL in a remote document"}
+	}
+
+	return url, nil
 }
 
-func findResources(s *session.SessionConfig) []string {
+func fetch(address string, s *session.SessionConfig) ([]byte, string, error) {
+	if s.Fetcher != nil {
+		return s.Fetcher.Fetch(address, s)
+	}
+
+	return net.SendRequest(address, s)
+}
+
+// Resolves a path found in a document against the origin of the document,
+// see WithRoot of the epoxy package for root.
+func Resolve(path, origin, root string) (string, error) {
+	return pathToURL(path, origin, root)
+}
+
+// Returns the paths of the resources referenced by body, as they are
+// written in the document and without duplicates. Data URLs are left out.
+func FindReferences(body []byte) []string {
 	var resources []string
 
-	matches_src := selectorHtmlSourceAttribute.FindAllString(string(s.Body), -1)
+	matches_src := selectorHtmlSourceAttribute.FindAllString(string(body), -1)
 
 	for i := 0; i < len(matches_src); i++ {
 		matches_src[i] = selectorHtmlSourceAttribute.ReplaceAllString(matches_src[i], "${1}")
@@ -115,7 +143,7 @@ func findResources(s *session.SessionConfig) []string {
 		}
 	}
 
-	matches_content := selectorHtmlContentAttribute.FindAllString(string(s.Body), -1)
+	matches_content := selectorHtmlContentAttribute.FindAllString(string(body), -1)
 
 	for i := 0; i < len(matches_content); i++ {
 		matches_content[i] = selectorHtmlContentAttribute.ReplaceAllString(matches_content[i], "${1}")
@@ -124,7 +152,7 @@ func findResources(s *session.SessionConfig) []string {
 		}
 	}
 
-	matches_href := selectorHtmlHrefAttribute.FindAllString(string(s.Body), -1)
+	matches_href := selectorHtmlHrefAttribute.FindAllString(string(body), -1)
 
 	for i := 0; i < len(matches_href); i++ {
 		matches_href[i] = selectorHtmlHrefAttribute.ReplaceAllString(matches_href[i], "${1}")
@@ -133,7 +161,7 @@ func findResources(s *session.SessionConfig) []string {
 		}
 	}
 
-	matches_url := selectorHtmlUrlAttribute.FindAllString(string(s.Body), -1)
+	matches_url := selectorHtmlUrlAttribute.FindAllString(string(body), -1)
 
 	for i := 0; i < len(matches_url); i++ {
 		matches_url[i] = selectorHtmlUrlAttribute.ReplaceAllString(matches_url[i], "${1}")
@@ -151,7 +179,11 @@ func findResources(s *session.SessionConfig) []string {
 		}
 	}
 
-	resources = unique_resources
+	return unique_resources
+}
+
+func findResources(s *session.SessionConfig) []string {
+	resources := FindReferences(s.Body)
 
 	if len(resources) > 1 {
 		log.Success("found " + strconv.Itoa(len(resources)) + " embeddable resources in " + log.BOLD + s.Source + log.RESET + ".")
@@ -170,30 +202,37 @@ func createDataURL(mimetype string, payload *[]byte) []byte {
 	return []byte("data:" + mimetype + ";base64," + encoded_body)
 }
 
-func embedResources(s *session.SessionConfig) session.SessionConfig {
+// Escapes body for use as a regexp replacement, where $ expands groups.
+func literal(body []byte) string {
+	return strings.Replace(string(body), "$", "$$", -1)
+}
+
+func embedResources(s *session.SessionConfig) {
 	matches_src := selectorHtmlSourceAttributeStrictValue.FindAllString(string(s.Body), -1)
 
 	for i := 0; i < len(matches_src); i++ {
 		path := selectorHtmlSourceAttributeStrictValueMem.ReplaceAllString(string(matches_src[i]), "${1}")
 
 		if selectorUriSchemeDataOrJavaScript.FindString(path) == "" {
-			address := pathToURL(path, s.Origin)
+			address, err := pathToURL(path, s.Origin, s.Root)
+			if err != nil {
+				continue
+			}
 
 			var body []byte
+			found := false
 
 			for a := 0; a < len(s.Resources); a++ {
 				if address == s.Resources[a].Address {
 					body = s.Resources[a].Body
+					found = true
 				}
 			}
 
-			if len(body) > 0 {
-				path = strings.Replace(path, "?", "\\?", -1)
-				path = strings.Replace(path, "-", "\\-", -1)
-				path = strings.Replace(path, ".", "\\.", -1)
-				path = strings.Replace(path, "+", "\\+", -1)
+			if found {
+				path = regexp.QuoteMeta(path)
 
-				new_source := regexp.MustCompile(`(?i)src=("|')` + path + `("|')`).ReplaceAllString(string(s.Body), "src=${1}" + string(body) + "${2}")
+				new_source := regexp.MustCompile(`(?i)src=("|')` + path + `("|')`).ReplaceAllString(string(s.Body), "src=${1}" + literal(body) + "${2}")
 				s.Body = []byte(new_source)
 			}
 		}
@@ -205,23 +244,25 @@ func embedResources(s *session.SessionConfig) session.SessionConfig {
 		path := selectorHtmlContentAttributeStrictValueMem.ReplaceAllString(string(matches_content[i]), "${1}")
 
 		if selectorUriSchemeDataOrJavaScript.FindString(path) == "" {
-			address := pathToURL(path, s.Origin)
+			address, err := pathToURL(path, s.Origin, s.Root)
+			if err != nil {
+				continue
+			}
 
 			var body []byte
+			found := false
 
 			for a := 0; a < len(s.Resources); a++ {
 				if address == s.Resources[a].Address {
 					body = s.Resources[a].Body
+					found = true
 				}
 			}
 
-			if len(body) > 0 {
-				path = strings.Replace(path, "?", "\\?", -1)
-				path = strings.Replace(path, "-", "\\-", -1)
-				path = strings.Replace(path, ".", "\\.", -1)
-				path = strings.Replace(path, "+", "\\+", -1)
+			if found {
+				path = regexp.QuoteMeta(path)
 
-				new_source := regexp.MustCompile(`(?i)content=("|')` + path + `("|')`).ReplaceAllString(string(s.Body), "content=${1}" + string(body) + "${2}")
+				new_source := regexp.MustCompile(`(?i)content=("|')` + path + `("|')`).ReplaceAllString(string(s.Body), "content=${1}" + literal(body) + "${2}")
 				s.Body = []byte(new_source)
 			}
 		}
@@ -233,23 +274,25 @@ func embedResources(s *session.SessionConfig) session.SessionConfig {
 		path := selectorHtmlHrefAttributeStrictValueMem.ReplaceAllString(string(matches_href[i]), "$1")
 
 		if selectorUriSchemeDataOrJavaScript.FindString(path) == "" {
-			address := pathToURL(path, s.Origin)
+			address, err := pathToURL(path, s.Origin, s.Root)
+			if err != nil {
+				continue
+			}
 
 			var body []byte
+			found := false
 
 			for a := 0; a < len(s.Resources); a++ {
 				if address == s.Resources[a].Address {
 					body = s.Resources[a].Body
+					found = true
 				}
 			}
 
-			if len(body) > 0 {
-				path = strings.Replace(path, "?", "\\?", -1)
-				path = strings.Replace(path, "-", "\\-", -1)
-				path = strings.Replace(path, ".", "\\.", -1)
-				path = strings.Replace(path, "+", "\\+", -1)
+			if found {
+				path = regexp.QuoteMeta(path)
 
-				new_source := regexp.MustCompile(`(?i)href=("|')` + path + `("|')`).ReplaceAllString(string(s.Body), "href=${1}" + string(body) + "${2}")
+				new_source := regexp.MustCompile(`(?i)href=("|')` + path + `("|')`).ReplaceAllString(string(s.Body), "href=${1}" + literal(body) + "${2}")
 				s.Body = []byte(new_source)			
 			}
 		}
@@ -261,144 +304,330 @@ func embedResources(s *session.SessionConfig) session.SessionConfig {
 		path := selectorHtmlUrlAttributeStrictValueMem.ReplaceAllString(matches_url[i], "$1")
 
 		if selectorUriSchemeDataOrJavaScript.FindString(path) == "" {
-			address := pathToURL(path, s.Origin)
+			address, err := pathToURL(path, s.Origin, s.Root)
+			if err != nil {
+				continue
+			}
 
 			var body []byte
+			found := false
 
 			for a := 0; a < len(s.Resources); a++ {
 				if address == s.Resources[a].Address {
 					body = s.Resources[a].Body
+					found = true
 				}
 			}
 
-			if len(body) > 0 {
-				path = strings.Replace(path, "?", "\\?", -1)
-				path = strings.Replace(path, "-", "\\-", -1)
-				path = strings.Replace(path, ".", "\\.", -1)
-				path = strings.Replace(path, "+", "\\+", -1)
+			if found {
+				path = regexp.QuoteMeta(path)
 
-				new_source := regexp.MustCompile(`(?i)url[(]("|'|)` + path + `("|'|)[)]`).ReplaceAllString(string(s.Body), "url(${1}" + string(body) + "${2})")
+				new_source := regexp.MustCompile(`(?i)url[(]("|'|)` + path + `("|'|)[)]`).ReplaceAllString(string(s.Body), "url(${1}" + literal(body) + "${2})")
 				s.Body = []byte(new_source)
 			}
 		}
 	}
+}
 
-	return *s
+// Reports whether resources of this type are parsed for nested resources.
+func Parsable(mimetype string) bool {
+	return selectorContentTypeCssHtmlSvg.FindString(mimetype) != ""
 }
 
-func Parse(s *session.SessionConfig) session.SessionConfig {
-	origin_path := selectorUriSchemeAndHost.ReplaceAllString(s.Origin, "")
+// Replaces every reference in body that resolves to one of the addresses in
+// replacements with the corresponding value, in the same places resources
+// are embedded in by Parse.
+func Rewrite(body []byte, origin, root string, replacements map[string]string) []byte {
+	s := &session.SessionConfig {
+		Origin:   origin,
+		Body:     body,
+		Options:  &session.Options{Root: root},
+	}
 
-	if origin_path == "" {
-		origin_path = "/"
-	} else if !strings.HasSuffix(origin_path, "/") {
-		origin_path = selectorUriLeadingUpToPath.ReplaceAllString(origin_path, "")
+	for address, replacement := range replacements {
+		s.Resources = append(s.Resources, session.Resource{Address: address, Body: []byte(replacement)})
+	}
+
+	embedResources(s)
+
+	return s.Body
+}
+
+// Reverses compression that is left in a body after the Content-Encoding
+// of the response was decoded, such as a pre-compressed .css.gz file that
+// is served with Content-Encoding: gzip once more. Bodies of compressed or
+// unknown types are left alone, and so is the body if it cannot be decoded,
+// unless it exceeds limit.
+func decodeLeftover(body []byte, content_type, address string, limit int64) ([]byte, error) {
+	if content_type == "" || selectorContentTypeCompressed.MatchString(content_type) {
+		return body, nil
+	}
+
+	// a few layers at most, there are files that decompress to themselves
+	for i := 0; i < 3; i++ {
+		coding := net.DetectEncoding(body)
+		if coding == "" {
+			return body, nil
+		}
+
+		decoded, err := net.Decode(body, coding, address, limit)
+		if errors.Is(err, net.ErrTooLarge) {
+			return body, err
+		} else if err != nil {
+			log.Warn("cannot decode " + coding + " compressed body of " + address + " (" + err.Error() + ")")
+			return body, nil
+		}
+
+		log.Info("decoded " + coding + " compressed body of " + address)
+
+		body = decoded
+	}
+
+	return body, nil
+}
+
+func Parse(s *session.SessionConfig) error {
+	// nested documents are scoped to the root document
+	if s.Depth == 0 && s.Scope.Origin == "" {
+		s.Scope.Origin = s.Origin
 	}
 
 	if s.Recurse != 0 {
+		if s.Depth == 0 {
+			s.Body, _ = decodeLeftover(s.Body, "text/html", s.Source, 0)
+		}
+
 		resources := findResources(s)
 
-		session.Depth++
+		if s.Depth == 0 && s.Confirm != nil && !s.Confirm(len(resources)) {
+			log.Raw("")
+			return nil
+		}
 
-		answer := ""
+		for i := 0; i < len(resources); i++ {
+			if resources[i] != "" && selectorUriSchemeDataOrJavaScript.FindString(resources[i]) == "" {
+				var resource session.Resource
 
-		if session.Depth == 1 {
-			answer = log.Prompt("fetch at least " + strconv.Itoa(len(resources)) + " resource(s)? Y/n")
-		}
+				address, err := pathToURL(resources[i], s.Origin, s.Root)
+				if err != nil {
+					log.Warn("skipping " + resources[i] + " (" + err.Error() + ")")
 
-		if session.Depth != 1 || answer != "n" && answer != "N" {
-			for i := 0; i < len(resources); i++ {
-				if resources[i] != "" && selectorUriSchemeDataOrJavaScript.FindString(resources[i]) == "" {
-					var resource session.Resource
+					if s.Summary != nil {
+						s.Summary.AddFailure(resources[i], err)
+					}
 
-					address := pathToURL(resources[i], s.Origin)
-					resource.Address = address
+					continue
+				}
 
-					if session.Depth <= s.Recurse {
-						s.RequestQueue.Add(1)
+				resource.Address = address
 
-						///// ASYNC /////
-						go func(path string) {
-							stripped_address := selectorUriSearchOrHash.ReplaceAllString(address, "")
+				denial := ""
 
-							extension := selectorUriFileExtension.FindString(stripped_address)
+				if rule := s.Rules.Match(address); rule != nil && !rule.Allow {
+					denial = rule.String()
+				} else if rule == nil && !s.Scope.Contains(address) {
+					denial = "scope " + s.Scope.Mode
+				}
 
-							extension_mimetype := strings.Replace(mime.TypeByExtension(extension) , " ", "", -1)
-							if extension_mimetype == "" { extension_mimetype = "unknown" }
+				if denial != "" {
+					log.Info("denying " + address + " (" + denial + ")")
 
-							if containsString(&s.Accept, extension_mimetype) {
-								body, content_type := net.SendRequest(address, s)
+					if s.Summary != nil {
+						s.Summary.AddDenial(denial)
+					}
 
-								content_type = strings.Replace(content_type, " ", "", -1)
+					if s.Denied == session.DeniedBlank || s.Denied == session.DeniedAboutBlank {
+						resource.Body = []byte("")
+						if s.Denied == session.DeniedAboutBlank {
+							resource.Body = []byte("about:blank")
+						}
+						resource.Placeholder = true
 
-								parsed_mimetype, err := filetype.Match(body)
-								if err != nil { log.Info("could not determine filetype, using Content-Type header value: " + content_type + "(" + err.Error() + ")") }
+						s.AddResource(resource)
+					}
 
-								parsed_mimetype.MIME.Value = strings.Replace(parsed_mimetype.MIME.Value, " ", "", -1)
+					continue
+				}
 
-								if parsed_mimetype.MIME.Value != "" {
-									content_type = parsed_mimetype.MIME.Value
-								}
+				s.RequestQueue.Add(1)
 
-								content_type = selectorSemiColonAndRest.ReplaceAllString(content_type, "")
+				///// ASYNC /////
+				go func(path string) {
+					defer s.RequestQueue.Done()
 
-								resource.Type = content_type
+					stripped_address := selectorUriSearchOrHash.ReplaceAllString(address, "")
 
-								if containsString(&s.Accept, content_type) {
-									log.Success(strconv.Itoa(len(body)) + " B " + log.BOLD + "[" + content_type + "]" + log.RESET + " " + address)
+					extension := selectorUriFileExtension.FindString(stripped_address)
 
-									if selectorContentTypeCssHtmlSvg.FindString(content_type) != "" {
-										_s := session.SessionConfig {
-											resource.Address,             // Source string
-											resource.Address,             // Origin string
-											body,                         // Body []byte
-											s.Accept,                     // Accept []string
-											(s.Recurse - session.Depth),  // Recurse int
-											[]session.Resource{},         // Resources []Resource
-											sync.WaitGroup{},             // RequestQueue sync.WaitGroup
-										}
+					extension_mimetype := strings.Replace(mime.TypeByExtension(extension) , " ", "", -1)
+					if extension_mimetype == "" { extension_mimetype = "unknown" }
 
-										_s = Parse(&_s)
+					if !s.Accepts(extension_mimetype) {
+						log.Info("skipping request: " + log.BOLD + "[" + extension_mimetype + "]" + log.RESET + " " + address)
+						return
+					}
 
-										resource.Body = _s.Body
-									} else {
-										resource.Body = body
-									}
+					// oversized resources are skipped, linked to or
+					// replaced by a placeholder
+					oversize := func(err error) {
+						log.Warn("skipping " + address + " (" + err.Error() + ")")
 
-									s.Resources = append(s.Resources, resource)
-								} else {
-									log.Info("skipping response: " + strconv.Itoa(len(body)) + " B " + log.BOLD + "[" + content_type + "]" + log.RESET + " " + address)
-								}
-							} else {
-								log.Info("skipping request: " + log.BOLD + "[" + extension_mimetype + "]" + log.RESET + " " + address)
+						if s.Summary != nil {
+							s.Summary.AddFailure(address, err)
+						}
+
+						if s.Limits.Oversize == session.OversizeLink {
+							resource.Body = []byte(address)
+						} else if s.Limits.Oversize == session.OversizePlaceholder {
+							resource.Body = []byte(s.Placeholder)
+							if s.Placeholder == "" {
+								resource.Body = []byte("about:blank")
 							}
+						} else {
+							return
+						}
+
+						resource.Placeholder = true
 
-							s.RequestQueue.Done()
-						}(resources[i])
-						///// SYNC /////
+						s.AddResource(resource)
+					}
+
+					body, content_type, err := fetch(address, s)
+					if errors.Is(err, net.ErrTooLarge) {
+						oversize(err)
+						return
+					} else if err != nil {
+						log.Warn("skipping " + address + " (" + err.Error() + ")")
+
+						if s.Summary != nil {
+							s.Summary.AddFailure(address, err)
+						}
+
+						if s.Placeholder != "" {
+							resource.Body = []byte(s.Placeholder)
+							resource.Placeholder = true
+
+							s.AddResource(resource)
+						}
+
+						return
+					}
+
+					content_type = strings.Replace(content_type, " ", "", -1)
+
+					// before sniffing, which would see an archive
+					body, err = decodeLeftover(body, content_type, address, s.MaxSize(content_type))
+					if err != nil {
+						oversize(err)
+						return
+					}
+
+					parsed_mimetype, err := filetype.Match(body)
+					if err != nil { log.Info("could not determine filetype, using Content-Type header value: " + content_type + "(" + err.Error() + ")") }
+
+					parsed_mimetype.MIME.Value = strings.Replace(parsed_mimetype.MIME.Value, " ", "", -1)
+
+					if parsed_mimetype.MIME.Value != "" {
+						content_type = parsed_mimetype.MIME.Value
+					} else if content_type == "" && extension_mimetype != "unknown" {
+						content_type = extension_mimetype
+					}
+
+					content_type = selectorSemiColonAndRest.ReplaceAllString(content_type, "")
+
+					resource.Type = content_type
+
+					if !s.Accepts(content_type) {
+						log.Info("skipping response: " + strconv.Itoa(len(body)) + " B " + log.BOLD + "[" + content_type + "]" + log.RESET + " " + address)
+						return
+					}
+
+					// fetchers other than HTTP don't stream, and the type
+					// may differ from the Content-Type header
+					if limit := s.MaxSize(content_type); limit > 0 && int64(len(body)) > limit {
+						oversize(&net.SizeError{URL: address, Limit: limit})
+						return
+					}
+
+					if s.Summary != nil && !s.Summary.Reserve(int64(len(body)), s.Limits.Total) {
+						oversize(&net.SizeError{URL: address, Limit: s.Limits.Total})
+						return
+					}
+
+					log.Success(strconv.Itoa(len(body)) + " B " + log.BOLD + "[" + content_type + "]" + log.RESET + " " + address)
+
+					if s.Recurse > 1 && selectorContentTypeCssHtmlSvg.FindString(content_type) != "" {
+						_s := session.SessionConfig {
+							Source:   resource.Address,
+							Origin:   resource.Address,
+							Body:     body,
+							Recurse:  s.Recurse - 1,
+							Depth:    s.Depth + 1,
+							Options:  s.Options,
+						}
+
+						err = Parse(&_s)
+						if err != nil {
+							log.Warn("skipping " + address + " (" + err.Error() + ")")
+
+							if s.Summary != nil {
+								s.Summary.AddFailure(address, err)
+							}
+
+							return
+						}
+
+						resource.Body = _s.Body
+
+						// archives reference nested resources by address
+						// instead of embedding them in their documents
+						if s.KeepReferences {
+							for a := 0; a < len(_s.Resources); a++ {
+								s.AddResource(_s.Resources[a])
+							}
+						}
+					} else {
+						resource.Body = body
 					}
-				}
-			}
 
-			s.RequestQueue.Wait()
+					s.AddResource(resource)
+				}(resources[i])
+				///// SYNC /////
+			}
+		}
 
-			if len(s.Resources) > 0 {
-				log.Info("generating base64 encoded data URLs ...")
+		s.RequestQueue.Wait()
 
-				for i := 0; i < len(s.Resources); i++ {
-					data_url := createDataURL(s.Resources[i].Type, &s.Resources[i].Body)
+		if len(s.Resources) > 0 && !s.KeepReferences {
+			log.Info("generating base64 encoded data URLs ...")
 
-					s.Resources[i].Body = data_url
+			for i := 0; i < len(s.Resources); i++ {
+				if s.Resources[i].Placeholder {
+					continue
 				}
 
-				log.Info("embedding resources in " + log.BOLD + s.Source + log.RESET + " ...")
+				data_url := createDataURL(s.Resources[i].Type, &s.Resources[i].Body)
 
-				*s = embedResources(s)
+				s.Resources[i].Body = data_url
 			}
 
-			return *s
-		} else {
-			log.Raw("")
+			log.Info("embedding resources in " + log.BOLD + s.Source + log.RESET + " ...")
+
+			embedResources(s)
+		} else if s.KeepReferences {
+			// archives keep references by address, except those to
+			// placeholders, which aren't stored
+			placeholders := map[string]string{}
+
+			for i := 0; i < len(s.Resources); i++ {
+				if s.Resources[i].Placeholder {
+					placeholders[s.Resources[i].Address] = string(s.Resources[i].Body)
+				}
+			}
+
+			if len(placeholders) > 0 {
+				s.Body = Rewrite(s.Body, s.Origin, s.Root, placeholders)
+			}
 		}
 	} else { // if s.Recurse is 0
 		content_type := ""
@@ -426,18 +655,16 @@ func Parse(s *session.SessionConfig) session.SessionConfig {
 
 		content_type = selectorSemiColonAndRest.ReplaceAllString(content_type, "")
 
-		if content_type != "" {
-			data_url := "data:" + content_type + ";base64,"
+		if content_type == "" {
+			return &DecodeError{s.Source}
+		}
 
-			encoded_body := base64.StdEncoding.EncodeToString(s.Body)
+		data_url := "data:" + content_type + ";base64,"
 
-			s.Body = []byte(data_url + encoded_body)
+		encoded_body := base64.StdEncoding.EncodeToString(s.Body)
 
-			return *s
-		} else {
-			log.Fatal("could not determine filetype, please specify it manually using -mimetype STRING")
-		}
+		s.Body = []byte(data_url + encoded_body)
 	}
 
-	return *s
+	return nil
 }
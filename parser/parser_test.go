@@ -0,0 +1,153 @@
+package parser
+
+import(
+	"sync"
+	"errors"
+	"testing"
+
+	"github.com/buffermet/epoxy/session"
+)
+
+func TestParseFileReferences(t *testing.T) {
+	tests := []struct {
+		origin string
+		body string
+		fetched []string
+		expected string
+		failures int
+	}{
+		{
+			"http://example.com/index.html",
+			`<img src="file:///etc/hostname">`,
+			nil,
+			`<img src="file:///etc/hostname">`,
+			1,
+		},
+		{
+			"https://example.com/index.html",
+			`<link href="file:///etc/hostname">`,
+			nil,
+			`<link href="file:///etc/hostname">`,
+			1,
+		},
+		{
+			"file:///srv/site/index.html",
+			`<img src="file:///srv/site/a.png">`,
+			[]string{"file:///srv/site/a.png"},
+			`<img src="data:image/png;base64,cG5n">`,
+			0,
+		},
+		{
+			"http://example.com/index.html",
+			`<img src="a.png">`,
+			[]string{"http://example.com/a.png"},
+			`<img src="data:image/png;base64,cG5n">`,
+			0,
+		},
+	}
+
+	for _, test := range tests {
+		var lock sync.Mutex
+		var fetched []string
+
+		options := session.DefaultOptions()
+		options.Fetcher = session.FetcherFunc(func(address string, s *session.SessionConfig) ([]byte, string, error) {
+			lock.Lock()
+			fetched = append(fetched, address)
+			lock.Unlock()
+
+			return []byte("png"), "image/png", nil
+		})
+
+		s := &session.SessionConfig{
+			Source:    test.origin,
+			Origin:    test.origin,
+			Body:      []byte(test.body),
+			Recurse:   1,
+			Resources: []session.Resource{},
+			Options:   options,
+		}
+
+		if err := Parse(s); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.origin, err)
+			continue
+		}
+
+		if len(fetched) != len(test.fetched) || (len(fetched) != 0 && fetched[0] != test.fetched[0]) {
+			t.Errorf("%s %s: fetched %v, expected %v", test.origin, test.body, fetched, test.fetched)
+		}
+		if string(s.Body) != test.expected {
+			t.Errorf("%s %s: body = %s, expected %s", test.origin, test.body, s.Body, test.expected)
+		}
+		if len(s.Summary.Failures) != test.failures {
+			t.Errorf("%s %s: %d failures, expected %d", test.origin, test.body, len(s.Summary.Failures), test.failures)
+		}
+		for _, failure := range s.Summary.Failures {
+			if !errors.Is(failure.Err, ErrResolve) {
+				t.Errorf("%s %s: failure %v is not a resolve error", test.origin, test.body, failure.Err)
+			}
+		}
+	}
+}
+
+func TestParseKeepReferences(t *testing.T) {
+	deny, _ := session.ParseRule(false, "path:/ads/*")
+
+	tests := []struct {
+		body string
+		denied string
+		placeholder string
+		limit int64
+		oversize string
+		expected string
+		resources int
+	}{
+		{`<img src="a.png">`, session.DeniedKeep, "", 0, session.OversizeSkip, `<img src="a.png">`, 1},
+		{`<img src="/ads/a.png">`, session.DeniedKeep, "", 0, session.OversizeSkip, `<img src="/ads/a.png">`, 0},
+		{`<img src="/ads/a.png">`, session.DeniedBlank, "", 0, session.OversizeSkip, `<img src="">`, 1},
+		{`<img src="/ads/a.png">`, session.DeniedAboutBlank, "", 0, session.OversizeSkip, `<img src="about:blank">`, 1},
+		{`<img src="missing.png">`, session.DeniedKeep, "about:blank", 0, session.OversizeSkip, `<img src="about:blank">`, 1},
+		{`<img src="missing.png">`, session.DeniedKeep, "", 0, session.OversizeSkip, `<img src="missing.png">`, 0},
+		{`<img src="a.png">`, session.DeniedKeep, "", 1, session.OversizeLink, `<img src="http://example.com/a.png">`, 1},
+		{`<img src="a.png">`, session.DeniedKeep, "", 1, session.OversizePlaceholder, `<img src="about:blank">`, 1},
+		{`<img src="a.png">`, session.DeniedKeep, "", 1, session.OversizeSkip, `<img src="a.png">`, 0},
+	}
+
+	for _, test := range tests {
+		options := session.DefaultOptions()
+		options.KeepReferences = true
+		options.Rules = session.Rules{deny}
+		options.Denied = test.denied
+		options.Placeholder = test.placeholder
+		options.Limits.Resource = test.limit
+		options.Limits.Oversize = test.oversize
+		options.Fetcher = session.FetcherFunc(func(address string, s *session.SessionConfig) ([]byte, string, error) {
+			if address == "http://example.com/missing.png" {
+				return nil, "", errors.New("not found")
+			}
+
+			return []byte("png"), "image/png", nil
+		})
+
+		s := &session.SessionConfig{
+			Source:    "http://example.com/index.html",
+			Origin:    "http://example.com/index.html",
+			Body:      []byte(test.body),
+			Recurse:   1,
+			Resources: []session.Resource{},
+			Options:   options,
+		}
+
+		if err := Parse(s); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.body, err)
+			continue
+		}
+
+		if string(s.Body) != test.expected {
+			t.Errorf("%s (denied %s, placeholder %q, oversize %s): body = %s, expected %s", test.body, test.denied, test.placeholder, test.oversize, s.Body, test.expected)
+		}
+		if len(s.Resources) != test.resources {
+			t.Errorf("%s (denied %s, placeholder %q, oversize %s): %d resources, expected %d", test.body, test.denied, test.placeholder, test.oversize, len(s.Resources), test.resources)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package session
+
+/*
+*	
+*	Browser profiles
+*	
+*	Headers that browsers send, so servers return the same variants of a
+*	resource to epoxy as they would to a browser.
+*	
+*/
+
+import(
+	"sort"
+	"strings"
+)
+
+type Browser struct {
+	UserAgent string
+	Language string              // Accept-Language
+	Encoding string              // Accept-Encoding
+	Accept map[string]string     // Accept per destination, see Destination
+}
+
+var chromeAccept = map[string]string {
+	"document":  "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+	"style":     "text/css,*/*;q=0.1",
+	"image":     "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+}
+
+// Browser profiles by name, for -browser.
+var Browsers = map[string]Browser {
+	"chrome": {
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/141.0.0.0 Safari/537.36",
+		"en-US,en;q=0.9",
+		"gzip, deflate, br, zstd",
+		chromeAccept,
+	},
+	"firefox": {
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:143.0) Gecko/20100101 Firefox/143.0",
+		"en-US,en;q=0.5",
+		"gzip, deflate, br, zstd",
+		map[string]string {
+			"document":  "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"style":     "text/css,*/*;q=0.1",
+			"image":     "image/avif,image/webp,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+			"font":      "application/font-woff2;q=1.0,application/font-woff;q=0.9,*/*;q=0.8",
+			"media":     "video/webm,video/ogg,video/*;q=0.9,application/ogg;q=0.7,audio/*;q=0.6,*/*;q=0.5",
+		},
+	},
+	"safari": {
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.6 Safari/605.1.15",
+		"en-US,en;q=0.9",
+		"gzip, deflate, br",
+		map[string]string {
+			"document":  "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"style":     "text/css,*/*;q=0.1",
+			"image":     "image/webp,image/avif,image/jxl,image/heic,image/heic-sequence,video/*;q=0.8,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+		},
+	},
+	"mobile": {
+		"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/141.0.0.0 Mobile Safari/537.36",
+		"en-US,en;q=0.9",
+		"gzip, deflate, br, zstd",
+		chromeAccept,
+	},
+}
+
+// Returns the names of the browser profiles in alphabetical order.
+func BrowserNames() []string {
+	names := []string{}
+	for name := range Browsers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Returns the Accept header that b sends for a resource of destination,
+// or */* if b sends no specific one.
+func (b Browser) AcceptHeader(destination string) string {
+	if accept, ok := b.Accept[destination]; ok {
+		return accept
+	}
+
+	return "*/*"
+}
+
+var destinations = map[string]string {
+	"html": "document", "htm": "document", "xhtml": "document", "php": "document", "asp": "document", "aspx": "document",
+	"css": "style",
+	"js": "script", "mjs": "script",
+	"png": "image", "jpg": "image", "jpeg": "image", "gif": "image", "webp": "image", "avif": "image", "svg": "image", "ico": "image", "bmp": "image", "tif": "image", "tiff": "image", "jxl": "image", "heic": "image",
+	"woff": "font", "woff2": "font", "ttf": "font", "otf": "font", "eot": "font",
+	"mp4": "media", "m4v": "media", "webm": "media", "ogv": "media", "mov": "media", "mp3": "media", "m4a": "media", "ogg": "media", "oga": "media", "wav": "media", "flac": "media",
+}
+
+// Returns what a browser would request address for, guessed from its file
+// extension: document, style, script, image, font, media, or "" if it is
+// unknown. Documents requested by the command line are always documents.
+func (s *SessionConfig) Destination(address string) string {
+	if address == s.Source {
+		return "document"
+	}
+
+	address = strings.SplitN(strings.SplitN(address, "#", 2)[0], "?", 2)[0]
+
+	name := address[strings.LastIndex(address, "/") + 1:]
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return destinations[strings.ToLower(name[i + 1:])]
+	}
+
+	return ""
+}
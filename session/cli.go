@@ -0,0 +1,877 @@
+package session
+
+/*
+*	
+*	Command line
+*	
+*	Turns the subcommand and flags of the command line into a session, and
+*	generates help and shell completion from the flag definitions.
+*	
+*/
+
+import(
+	"os"
+	"flag"
+	"sync"
+	"sort"
+	"time"
+	"errors"
+	"strconv"
+	"strings"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/buffermet/epoxy/log"
+)
+
+type command struct {
+	name string
+	summary string
+	groups []string
+}
+
+// Subcommands in the order they are listed in the help output, with the
+// option groups they accept.
+var commands = []command {
+	{"embed",      "embed the resources of an HTML, CSS or SVG document (default)", []string{"output", "source", "embed", "retry", "fetch", "rules", "limits", "filter", "connection", "config"}},
+	{"encode",     "encode a single file as a data URL", []string{"output", "source", "retry", "fetch", "connection", "config"}},
+	{"extract",    "decode the data URLs of a document into files", []string{"output", "source", "retry", "fetch", "connection", "config"}},
+	{"inspect",    "list the resources and data URLs of a document", []string{"source", "retry", "fetch", "connection", "config"}},
+	{"completion", "print a completion script for bash, zsh or fish", []string{}},
+}
+
+var groupTitles = map[string]string {
+	"output":     "Output",
+	"source":     "Source",
+	"embed":      "Embedding",
+	"retry":      "Retries",
+	"fetch":      "Fetching",
+	"rules":      "Scope and URL rules",
+	"limits":     "Size limits",
+	"filter":     "File types",
+	"connection": "Connection",
+	"config":     "Configuration",
+}
+
+// Aliases of -reject for common file types, defined as -no-<name>.
+var rejectFlags = []struct {
+	name string
+	mimetypes []string
+} {
+	{"unknown", []string{"unknown", "application/octet-stream"}},
+	{"svg",     []string{"image/svg+xml", "image/svg"}},
+	{"jpg",     []string{"image/jpeg"}},
+	{"png",     []string{"image/png"}},
+	{"gif",     []string{"image/gif"}},
+	{"webp",    []string{"image/webp"}},
+	{"cr2",     []string{"image/x-canon-cr2"}},
+	{"tif",     []string{"image/tiff"}},
+	{"bmp",     []string{"image/bmp"}},
+	{"jxr",     []string{"image/vnd.ms-photo"}},
+	{"psd",     []string{"image/vnd.adobe.photoshop"}},
+	{"ico",     []string{"image/vnd.microsoft.icon", "image/x-icon"}},
+	{"mp4",     []string{"video/mp4"}},
+	{"m4v",     []string{"video/x-m4v"}},
+	{"mkv",     []string{"video/x-matroska"}},
+	{"webm",    []string{"video/webm"}},
+	{"mov",     []string{"video/quicktime"}},
+	{"avi",     []string{"video/x-msvideo"}},
+	{"wmv",     []string{"video/x-ms-wmv"}},
+	{"mpg",     []string{"video/mpeg"}},
+	{"flv",     []string{"video/x-flv"}},
+	{"mid",     []string{"audio/midi"}},
+	{"mp3",     []string{"audio/mpeg"}},
+	{"m4a",     []string{"audio/m4a"}},
+	{"ogg",     []string{"audio/ogg"}},
+	{"flac",    []string{"audio/x-flac"}},
+	{"wav",     []string{"audio/x-wav"}},
+	{"amr",     []string{"audio/amr"}},
+	{"epub",    []string{"application/epub+zip"}},
+	{"zip",     []string{"application/zip"}},
+	{"tar",     []string{"application/x-tar"}},
+	{"rar",     []string{"application/x-rar-compressed"}},
+	{"gz",      []string{"application/gzip"}},
+	{"bz2",     []string{"application/x-bzip2"}},
+	{"7z",      []string{"application/x-7z-compressed"}},
+	{"xz",      []string{"application/x-xz"}},
+	{"pdf",     []string{"application/pdf"}},
+	{"exe",     []string{"application/x-msdownload"}},
+	{"swf",     []string{"application/x-shockwave-flash"}},
+	{"rtf",     []string{"application/rtf"}},
+	{"eot",     []string{"application/vnd.ms-fontobject", "font/eot"}},
+	{"ps",      []string{"application/postscript"}},
+	{"sqlite",  []string{"application/x-sqlite3"}},
+	{"nes",     []string{"application/x-nintendo-nes-rom"}},
+	{"crx",     []string{"application/x-google-chrome-extension"}},
+	{"cab",     []string{"application/vnd.ms-cab-compressed"}},
+	{"deb",     []string{"application/x-deb"}},
+	{"ar",      []string{"application/x-unix-archive"}},
+	{"z",       []string{"application/x-compress"}},
+	{"lz",      []string{"application/x-lzip"}},
+	{"rpm",     []string{"application/x-rpm"}},
+	{"elf",     []string{"application/x-executable"}},
+	{"doc",     []string{"application/msword"}},
+	{"docx",    []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document"}},
+	{"xls",     []string{"application/vnd.ms-excel"}},
+	{"xlsx",    []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}},
+	{"ppt",     []string{"application/vnd.ms-powerpoint"}},
+	{"pptx",    []string{"application/vnd.openxmlformats-officedocument.presentationml.presentation"}},
+	{"woff",    []string{"application/font-woff", "font/woff"}},
+	{"woff2",   []string{"application/font-woff", "font/woff2"}},
+	{"ttf",     []string{"application/font-sfnt", "font/ttf"}},
+	{"otf",     []string{"application/font-sfnt", "font/otf"}},
+	{"css",     []string{"text/css"}},
+	{"html",    []string{"text/html"}},
+	{"js",      []string{"text/javascript", "application/javascript", "application/x-javascript"}},
+	{"json",    []string{"application/json"}},
+}
+
+// Settings of the command line that concern the epoxy command rather than
+// the session, such as where the payload is saved.
+type CLI struct {
+	Command string         // subcommand, embed if none is given
+	Shell string           // shell of the completion command
+	Format string          // output format of embed and extract
+	Output string          // payload path, - for stdout, "" for the default
+	Mode os.FileMode       // permissions of the payload
+	Mirrors []string       // -mirror directories
+	Replays []string       // -replay WARC and HAR files
+	Insecure bool          // TLS certificates are not verified
+	Cores int              // procs for async parsing
+}
+
+// Collects flags that repeat, such as -mirror.
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Accepts or rejects the MIME types of a list given on the command line,
+// in the order the flags are given.
+type mimetypeValue struct {
+	reject bool
+	s *SessionConfig
+}
+
+// Left empty so help doesn't list the default MIME types as default value.
+func (v *mimetypeValue) String() string {
+	return ""
+}
+
+func (v *mimetypeValue) Set(value string) error {
+	mimetypes, err := ParseMimetypes(value)
+	if err != nil {
+		return err
+	}
+
+	if v.reject {
+		v.s.RejectMimetypes(mimetypes)
+	} else {
+		v.s.Accept = append(v.s.Accept, mimetypes...)
+	}
+
+	return nil
+}
+
+// Adds a rule given on the command line to the rules of a session, in the
+// order the flags are given.
+type ruleValue struct {
+	allow bool
+	s *SessionConfig
+}
+
+func (v *ruleValue) String() string {
+	return ""
+}
+
+func (v *ruleValue) Set(value string) error {
+	rule, err := ParseRule(v.allow, value)
+	if err != nil {
+		return err
+	}
+
+	v.s.Rules = append(v.s.Rules, rule)
+
+	return nil
+}
+
+// Adds the rules of a rules file at the position of the flag.
+type rulesFileValue struct {
+	s *SessionConfig
+}
+
+func (v *rulesFileValue) String() string {
+	return ""
+}
+
+func (v *rulesFileValue) Set(value string) error {
+	rules, err := ReadRules(value)
+	if err != nil {
+		return err
+	}
+
+	v.s.Rules = append(v.s.Rules, rules...)
+
+	return nil
+}
+
+// Rejects a fixed list of MIME types when set, for the -no-* flags.
+type rejectValue struct {
+	mimetypes []string
+	s *SessionConfig
+}
+
+func (v *rejectValue) String() string {
+	return "false"
+}
+
+func (v *rejectValue) Set(value string) error {
+	reject, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+
+	if reject {
+		v.s.RejectMimetypes(v.mimetypes)
+	}
+
+	return nil
+}
+
+func (v *rejectValue) IsBoolFlag() bool {
+	return true
+}
+
+// Raw values of flags that are validated once every flag is parsed.
+type arguments struct {
+	print bool
+	output string
+	cores int
+	mirrors []string
+	replays []string
+	scope_hosts []string
+	max_resource_size string
+	max_total_size string
+	max_sizes []string
+	mode string
+	format string
+	root string
+	retries int
+	retry_delay int
+	retry_max_delay int
+	config string
+	profile string
+	headers []string
+	cookies []string
+	cookie_files []string
+	proxy string
+	ca_files []string
+	client_cert string
+	client_key string
+	insecure bool
+}
+
+func lookupCommand(name string) (command, bool) {
+	for i := 0; i < len(commands); i++ {
+		if commands[i].name == name {
+			return commands[i], true
+		}
+	}
+
+	return command{}, false
+}
+
+// Defines the flags of an option group on fs. Flags that are not specific
+// to a session, such as the output path, are stored in a and copied to the
+// package variables once parsed.
+func defineGroup(fs *flag.FlagSet, group string, c command, s *SessionConfig, a *arguments) {
+	switch group {
+	case "output":
+		fs.BoolVar(&a.print, "print", false, "print payload to stdout (same as -output -).")
+		output := map[string]string{"embed": "epoxy-<source>", "encode": "<source>.url", "extract": "<source>-extracted"}[c.name]
+
+		fs.StringVar(&a.output, "output", "", "save the payload to `PATH`, - for stdout (default=" + output + ").")
+		fs.StringVar(&a.mode, "mode", "0600", "save the payload with permissions `OCTAL`.")
+
+		if c.name == "embed" {
+			fs.StringVar(&a.format, "format", "html", "save the payload in `FORMAT`: html to embed resources as data URLs, mhtml to save them in a multipart/related archive, warc to save every HTTP exchange in a WARC 1.1 file, gzipped if PATH ends in .gz, dir to save the page as index.html next to an assets/ directory, or zip to pack that directory.")
+		} else if c.name == "extract" {
+			fs.StringVar(&a.format, "format", "dir", "save the payload in `FORMAT`: dir to save the document as index.html next to an assets/ directory, or zip to pack that directory.")
+		}
+	case "source":
+		fs.StringVar(&s.Source, "source", "", "read the source from `PATH`, - for stdin, or retrieve it from a URL, may also be given as the last argument.")
+		fs.StringVar(&s.Origin, "origin", "", "full `URL` to source file (default=URL of -source after redirects).")
+	case "embed":
+		fs.IntVar(&s.Recurse, "recurse", 1, "embed resources up to `INT` levels deep, 0 to encode the source as a data URL.")
+		fs.IntVar(&a.cores, "cores", 4, "use up to `INT` procs for async parsing.")
+		fs.StringVar(&s.Placeholder, "placeholder", "", "replace references that cannot be retrieved with `STRING` (e.g. about:blank) instead of leaving them untouched.")
+	case "retry":
+		fs.IntVar(&a.retries, "retries", s.Retry.Attempts, "make up to `INT` attempts per request on network errors, 429 and 5xx.")
+		fs.IntVar(&a.retry_delay, "retry-delay", int(s.Retry.Delay / time.Millisecond), "wait `MS` before the first retry, doubled per attempt.")
+		fs.IntVar(&a.retry_max_delay, "retry-max-delay", int(s.Retry.MaxDelay / time.Millisecond), "wait at most `MS` for backoff and Retry-After.")
+	case "fetch":
+		fs.Var((*listValue)(&a.mirrors), "mirror", "retrieve resources from a local copy of the site in `DIR` (e.g. made with wget --mirror) instead of the network, can be repeated.")
+		fs.Var((*listValue)(&a.replays), "replay", "answer requests from the responses recorded in the WARC or HAR `FILE` instead of the network, can be repeated.")
+		fs.StringVar(&a.root, "root", "", "confine file:// origins to `DIR`, paths starting with a slash resolve against it.")
+		fs.StringVar(&s.Cache, "cache", "", "keep responses in `DIR` and serve them from there while fresh by their Cache-Control or Expires headers, revalidating them with ETag and Last-Modified once stale.")
+		fs.BoolVar(&s.Offline, "offline", false, "serve every request from -cache, fresh or not, and fail on resources that are not cached.")
+		fs.StringVar(&s.Browser, "browser", s.Browser, "send the User-Agent, Accept, Accept-Language and Accept-Encoding headers of `NAME` for each type of resource: " + strings.Join(BrowserNames(), ", ") + ".")
+		fs.StringVar(&s.UserAgent, "user-agent", "", "send `STRING` as User-Agent instead of the one of -browser.")
+		fs.Var((*listValue)(&a.headers), "header", "send the header `\"NAME: VALUE\"` with every request, can be repeated.")
+		fs.Var((*listValue)(&a.cookies), "cookie", "send the cookies in `STRING` (e.g. 'session=abc; lang=en') to the host of -origin, or of the source if it is a URL, can be repeated.")
+		fs.Var((*listValue)(&a.cookie_files), "cookies", "send the cookies of the Netscape cookies.txt `FILE`, as exported by browsers and curl, can be repeated.")
+	case "rules":
+		fs.Var(&ruleValue{true, s}, "allow", "allow URLs matched by `RULE`, one of host:NAME, suffix:DOMAIN, path:GLOB or regex:EXPR, can be repeated.")
+		fs.Var(&ruleValue{false, s}, "deny", "don't retrieve URLs matched by `RULE`, the first matching -allow or -deny decides before -scope, can be repeated.")
+		fs.Var(&rulesFileValue{s}, "rules", "read allow and deny rules from `FILE`, one per line (e.g. deny suffix:google-analytics.com).")
+		fs.StringVar(&s.Scope.Mode, "scope", ScopeAny, "only retrieve resources in `MODE` of the source at every level of recursion: any, same-origin, same-site (same registrable domain) or hosts to allow -scope-host only.")
+		fs.Var((*listValue)(&a.scope_hosts), "scope-host", "keep the hosts in `LIST` in scope, a leading dot includes subdomains (e.g. .cdn.example.net), can be repeated.")
+		fs.StringVar(&s.Denied, "denied", DeniedKeep, "`ACTION` for references to denied and out of scope URLs: keep to leave them untouched, blank to empty them or about:blank.")
+	case "limits":
+		fs.StringVar(&a.max_resource_size, "max-resource-size", "", "abort downloads of resources larger than `SIZE` in bytes, or with a K, M or G suffix (e.g. 10M).")
+		fs.StringVar(&a.max_total_size, "max-total-size", "", "abort downloads once `SIZE` bytes have been retrieved in total.")
+		fs.Var((*listValue)(&a.max_sizes), "max-size", "limit resources of a type, given as `TYPE=SIZE` with a MIME type or wildcard (e.g. video/*=2M), instead of -max-resource-size, can be repeated.")
+		fs.StringVar(&s.Limits.Oversize, "oversize", OversizeSkip, "`ACTION` for references to resources exceeding a limit: skip to leave them untouched, link to point them to the absolute URL, or placeholder to replace them with -placeholder or about:blank.")
+	case "filter":
+		fs.Var(&mimetypeValue{false, s}, "accept", "also embed files of the types in `LIST`, a comma separated list of MIME types, wildcards such as image/* and file extensions such as .avif, can be repeated.")
+		fs.Var(&mimetypeValue{true, s}, "reject", "don't embed files of the types in `LIST`, unless accepted by a more specific type given later (e.g. -reject '*/*' -accept 'image/*'), can be repeated.")
+
+		for i := 0; i < len(rejectFlags); i++ {
+			usage := "same as -reject " + strings.Join(rejectFlags[i].mimetypes, ",") + "."
+			if rejectFlags[i].name == "unknown" {
+				usage = "don't embed unknown filetypes, same as -reject unknown,application/octet-stream."
+			}
+
+			fs.Var(&rejectValue{rejectFlags[i].mimetypes, s}, "no-" + rejectFlags[i].name, usage)
+		}
+	case "connection":
+		fs.StringVar(&a.proxy, "proxy", "", "send requests through the http, https or socks5 proxy at `URL` (default=$HTTPS_PROXY or $HTTP_PROXY, except for $NO_PROXY).")
+		fs.Var((*listValue)(&a.ca_files), "ca-cert", "also trust the certificate authorities in the PEM `FILE`, can be repeated.")
+		fs.StringVar(&a.client_cert, "client-cert", "", "authenticate with the PEM client certificate in `FILE`.")
+		fs.StringVar(&a.client_key, "client-key", "", "read the key of -client-cert from the PEM `FILE` (default=-client-cert).")
+		fs.BoolVar(&a.insecure, "insecure", false, "don't verify TLS certificates, which lets anyone on the network alter the payload.")
+	case "config":
+		// read by applyConfig before the other flags are parsed
+		fs.StringVar(&a.config, "config", "", "read default flag values from the TOML, YAML or JSON `FILE`, by extension, empty to read none (default=./epoxy.toml or $XDG_CONFIG_HOME/epoxy/config.toml).")
+		fs.StringVar(&a.profile, "profile", "", "also apply the flags of profile `NAME` in the config file (e.g. email-safe).")
+	}
+}
+
+func newFlagSet(c command, s *SessionConfig, a *arguments) *flag.FlagSet {
+	fs := flag.NewFlagSet("epoxy " + c.name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+
+	for i := 0; i < len(c.groups); i++ {
+		defineGroup(fs, c.groups[i], c, s, a)
+	}
+
+	return fs
+}
+
+// Returns the flags defined by group, in alphabetical order.
+func groupFlags(c command, group string) []*flag.Flag {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defineGroup(fs, group, c, &SessionConfig{Options: DefaultOptions()}, &arguments{})
+
+	flags := []*flag.Flag{}
+	fs.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+
+	return flags
+}
+
+// Breaks text into lines of at most width characters.
+func wrap(text string, width int) []string {
+	lines := []string{}
+	line := ""
+
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line) + 1 + len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+
+	return append(lines, line)
+}
+
+// Prints the commands, and the options of command.
+func ShowOptions(command string) {
+	c, _ := lookupCommand(command)
+
+	str := "usage: epoxy [command] <options> [source]\n" + 
+	       "\n" + 
+	       "Commands:\n" + 
+	       "\n"
+
+	for i := 0; i < len(commands); i++ {
+		str += "  " + commands[i].name + strings.Repeat(" ", 13 - len(commands[i].name)) + commands[i].summary + "\n"
+	}
+
+	if len(c.groups) > 0 {
+		str += "\n" + 
+		       "Options of epoxy " + c.name + ", see epoxy help <command> for the others.\n"
+	}
+
+	for i := 0; i < len(c.groups); i++ {
+		str += "\n" + groupTitles[c.groups[i]] + ":\n\n"
+
+		flags := groupFlags(c, c.groups[i])
+
+		for a := 0; a < len(flags); a++ {
+			name, usage := flag.UnquoteUsage(flags[a])
+
+			if flags[a].DefValue != "" && flags[a].DefValue != "0" && flags[a].DefValue != "false" && !strings.Contains(usage, "(default=") {
+				usage = strings.TrimSuffix(usage, ".") + " (default=" + flags[a].DefValue + ")."
+			}
+
+			left := "  -" + flags[a].Name
+			if name != "" {
+				left += " " + name
+			}
+
+			lines := wrap(usage, 56)
+
+			if len(left) > 23 {
+				str += left + "\n"
+			} else {
+				lines[0] = left + strings.Repeat(" ", 24 - len(left)) + lines[0]
+			}
+
+			for l := 0; l < len(lines); l++ {
+				if l > 0 || len(left) > 23 {
+					lines[l] = strings.Repeat(" ", 24) + lines[l]
+				}
+
+				str += lines[l] + "\n"
+			}
+		}
+	}
+
+	if command == "completion" {
+		str += "\n" + 
+		       "  epoxy completion bash > /etc/bash_completion.d/epoxy\n" + 
+		       "  epoxy completion zsh > \"${fpath[1]}/_epoxy\"\n" + 
+		       "  epoxy completion fish > ~/.config/fish/completions/epoxy.fish\n"
+	}
+
+	log.Raw(str)
+}
+
+// Returns a script that completes the commands and flags of epoxy in
+// shell, and file names otherwise.
+func Completion(shell string) (string, error) {
+	names := []string{}
+	for i := 0; i < len(commands); i++ {
+		names = append(names, commands[i].name)
+	}
+
+	command_flags := map[string][]string{}
+	for i := 0; i < len(commands); i++ {
+		for g := 0; g < len(commands[i].groups); g++ {
+			flags := groupFlags(commands[i], commands[i].groups[g])
+			for f := 0; f < len(flags); f++ {
+				command_flags[commands[i].name] = append(command_flags[commands[i].name], "-" + flags[f].Name)
+			}
+		}
+		sort.Strings(command_flags[commands[i].name])
+	}
+
+	switch shell {
+	case "bash", "zsh":
+		str := ""
+		if shell == "zsh" {
+			str += "#compdef epoxy\n" + 
+			       "autoload -U +X bashcompinit && bashcompinit\n"
+		}
+
+		str += "_epoxy() {\n" + 
+		       "\tlocal cur=${COMP_WORDS[COMP_CWORD]}\n" + 
+		       "\tif [ $COMP_CWORD -eq 1 ] && [[ $cur != -* ]]; then\n" + 
+		       "\t\tCOMPREPLY=( $(compgen -W \"" + strings.Join(names, " ") + "\" -- \"$cur\") )\n" + 
+		       "\t\treturn\n" + 
+		       "\tfi\n" + 
+		       "\tif [[ $cur == -* ]]; then\n" + 
+		       "\t\tcase ${COMP_WORDS[1]} in\n"
+
+		for i := 0; i < len(commands); i++ {
+			if len(command_flags[commands[i].name]) > 0 {
+				str += "\t\t\t" + commands[i].name + ") COMPREPLY=( $(compgen -W \"" + strings.Join(command_flags[commands[i].name], " ") + "\" -- \"$cur\") ) ;;\n"
+			}
+		}
+
+		str += "\t\t\t*) COMPREPLY=( $(compgen -W \"" + strings.Join(command_flags["embed"], " ") + "\" -- \"$cur\") ) ;;\n" + 
+		       "\t\tesac\n" + 
+		       "\t\treturn\n" + 
+		       "\tfi\n" + 
+		       "\tif [ \"${COMP_WORDS[1]}\" = completion ]; then\n" + 
+		       "\t\tCOMPREPLY=( $(compgen -W \"bash zsh fish\" -- \"$cur\") )\n" + 
+		       "\t\treturn\n" + 
+		       "\tfi\n" + 
+		       "\tCOMPREPLY=( $(compgen -f -- \"$cur\") )\n" + 
+		       "}\n" + 
+		       "complete -o filenames -F _epoxy epoxy\n"
+
+		return str, nil
+	case "fish":
+		str := "complete -c epoxy -f -n __fish_use_subcommand -a \"" + strings.Join(names, " ") + "\"\n" + 
+		       "complete -c epoxy -f -n \"__fish_seen_subcommand_from completion\" -a \"bash zsh fish\"\n"
+
+		for i := 0; i < len(commands); i++ {
+			for g := 0; g < len(commands[i].groups); g++ {
+				flags := groupFlags(commands[i], commands[i].groups[g])
+
+				for f := 0; f < len(flags); f++ {
+					name, usage := flag.UnquoteUsage(flags[f])
+
+					str += "complete -c epoxy -n \"__fish_seen_subcommand_from " + commands[i].name + "\" -o " + flags[f].Name
+					if name != "" {
+						str += " -r"
+					}
+					str += " -d " + strconv.Quote(wrap(usage, 60)[0]) + "\n"
+				}
+			}
+		}
+
+		return str, nil
+	}
+
+	return "", &ConfigError{"unsupported shell", shell, nil}
+}
+
+// Parses args, allowing the source to be given among the flags.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+
+	for {
+		err := fs.Parse(args)
+		if errors.Is(err, flag.ErrHelp) {
+			return positional, ErrHelp
+		} else if err != nil {
+			return positional, &ConfigError{"invalid parameter", "", err}
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Parses the command line args, without the program name, into a session
+// and the settings of the command.
+func NewSession(args []string) (*SessionConfig, *CLI, error) {
+	s := &SessionConfig { 
+		"",                // Source string
+		"",                // Origin string
+		[]byte(""),        // Body []byte
+		1,                 // Recurse int
+		0,                 // Depth int
+		[]Resource{},      // Resources []Resource
+		sync.WaitGroup{},  // RequestQueue sync.WaitGroup
+		sync.Mutex{},      // ResourcesLock sync.Mutex
+		DefaultOptions(),  // *Options
+	}
+
+	cli := &CLI {
+		"embed",           // Command string
+		"",                // Shell string
+		"html",            // Format string
+		"",                // Output string
+		0600,              // Mode os.FileMode
+		[]string{},        // Mirrors []string
+		[]string{},        // Replays []string
+		false,             // Insecure bool
+		4,                 // Cores int
+	}
+
+	// without a command, flags are those of embed
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if args[0] == "help" {
+			if len(args) > 1 {
+				if _, ok := lookupCommand(args[1]); ok {
+					cli.Command = args[1]
+				}
+			}
+
+			return s, cli, ErrHelp
+		}
+
+		if _, ok := lookupCommand(args[0]); !ok {
+			return s, cli, &ConfigError{"unknown command", args[0], nil}
+		}
+
+		cli.Command = args[0]
+		args = args[1:]
+	}
+
+	c, _ := lookupCommand(cli.Command)
+	a := &arguments{}
+
+	fs := newFlagSet(c, s, a)
+
+	if len(c.groups) > 0 {
+		err := applyConfig(fs, cli.Command, args)
+		if err != nil {
+			return s, cli, err
+		}
+	}
+
+	// rules on the command line are matched before those of the config
+	// file, as the first matching rule decides
+	file_rules := s.Rules
+	s.Rules = Rules{}
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return s, cli, err
+	}
+
+	s.Rules = append(s.Rules, file_rules...)
+
+	if cli.Command == "completion" {
+		if len(positional) != 1 {
+			return s, cli, &ConfigError{"missing parameter", "shell", nil}
+		}
+
+		cli.Shell = positional[0]
+
+		return s, cli, nil
+	}
+
+	if len(positional) > 1 || (len(positional) == 1 && s.Source != "") {
+		return s, cli, &ConfigError{"more than one source", strings.Join(positional, " "), nil}
+	} else if len(positional) == 1 {
+		s.Source = positional[0]
+	}
+
+	if s.Source == "" {
+		return s, cli, &ConfigError{"missing parameter", "-source", nil}
+	}
+
+	cli.Output = a.output
+	if a.print {
+		cli.Output = "-"
+	}
+
+	cli.Mirrors = a.mirrors
+	cli.Replays = a.replays
+
+	if a.format != "" {
+		valid := []string{"html", "mhtml", "warc", "dir", "zip"}
+		if cli.Command == "extract" {
+			valid = []string{"dir", "zip"}
+		}
+
+		found := false
+		for i := 0; i < len(valid); i++ {
+			found = found || valid[i] == a.format
+		}
+
+		if !found {
+			return s, cli, &ConfigError{"invalid output format", a.format, nil}
+		}
+
+		cli.Format = a.format
+	}
+
+	if a.mode != "" {
+		mode, err := strconv.ParseUint(a.mode, 8, 32)
+		if err != nil {
+			return s, cli, &ConfigError{"invalid file mode", a.mode, err}
+		}
+
+		cli.Mode = os.FileMode(mode)
+	}
+
+	if a.root != "" {
+		root, err := filepath.Abs(a.root)
+		if err != nil {
+			return s, cli, &ConfigError{"invalid root directory", a.root, err}
+		}
+
+		s.Root = root
+	}
+
+	if s.Offline && s.Cache == "" {
+		return s, cli, &ConfigError{"missing parameter", "-cache", nil}
+	}
+
+	if a.client_key != "" && a.client_cert == "" {
+		return s, cli, &ConfigError{"missing parameter", "-client-cert", nil}
+	}
+
+	transport, err := NewTransport(TransportConfig{a.proxy, a.ca_files, a.client_cert, a.client_key, a.insecure})
+	if err != nil {
+		return s, cli, err
+	}
+
+	s.Transport = transport
+	cli.Insecure = a.insecure
+
+	if _, ok := Browsers[s.Browser]; !ok {
+		return s, cli, &ConfigError{"unknown browser", s.Browser, nil}
+	}
+
+	for i := 0; i < len(a.headers); i++ {
+		name, value, err := ParseHeader(a.headers[i])
+		if err != nil {
+			return s, cli, err
+		}
+
+		s.Headers.Add(name, value)
+	}
+
+	// cookies set by responses are sent along with the imported ones
+	s.Jar = NewCookieJar()
+
+	for i := 0; i < len(a.cookie_files); i++ {
+		err := ReadCookies(a.cookie_files[i], s.Jar)
+		if err != nil {
+			return s, cli, err
+		}
+	}
+
+	if len(a.cookies) > 0 {
+		address := s.Origin
+		if address == "" && IsURL(s.Source) {
+			address = s.Source
+		}
+
+		if address == "" {
+			return s, cli, &ConfigError{"missing parameter", "-origin", nil}
+		}
+
+		for i := 0; i < len(a.cookies); i++ {
+			err := AddCookies(s.Jar, address, a.cookies[i])
+			if err != nil {
+				return s, cli, err
+			}
+		}
+	}
+
+	if s.Denied != DeniedKeep && s.Denied != DeniedBlank && s.Denied != DeniedAboutBlank {
+		return s, cli, &ConfigError{"invalid action for denied URLs", s.Denied, nil}
+	}
+
+	if s.Scope.Mode != ScopeAny && s.Scope.Mode != ScopeSameOrigin && s.Scope.Mode != ScopeSameSite && s.Scope.Mode != ScopeHosts {
+		return s, cli, &ConfigError{"invalid scope", s.Scope.Mode, nil}
+	}
+
+	for i := 0; i < len(a.scope_hosts); i++ {
+		for _, host := range strings.Split(a.scope_hosts[i], ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				s.Scope.Hosts = append(s.Scope.Hosts, host)
+			}
+		}
+	}
+
+	if s.Scope.Mode == ScopeHosts && len(s.Scope.Hosts) == 0 {
+		return s, cli, &ConfigError{"missing parameter", "-scope-host", nil}
+	}
+
+	if a.max_resource_size != "" {
+		s.Limits.Resource, err = ParseSize(a.max_resource_size)
+		if err != nil {
+			return s, cli, err
+		}
+	}
+
+	if a.max_total_size != "" {
+		s.Limits.Total, err = ParseSize(a.max_total_size)
+		if err != nil {
+			return s, cli, err
+		}
+	}
+
+	for i := 0; i < len(a.max_sizes); i++ {
+		parts := strings.SplitN(a.max_sizes[i], "=", 2)
+		if len(parts) != 2 {
+			return s, cli, &ConfigError{"invalid size limit", a.max_sizes[i], nil}
+		}
+
+		mimetypes, err := ParseMimetypes(parts[0])
+		if err != nil {
+			return s, cli, err
+		}
+
+		size, err := ParseSize(parts[1])
+		if err != nil {
+			return s, cli, err
+		}
+
+		for m := 0; m < len(mimetypes); m++ {
+			s.Limits.Classes[mimetypes[m]] = size
+		}
+	}
+
+	if s.Limits.Oversize != OversizeSkip && s.Limits.Oversize != OversizeLink && s.Limits.Oversize != OversizePlaceholder {
+		return s, cli, &ConfigError{"invalid action for oversized resources", s.Limits.Oversize, nil}
+	}
+
+	if cli.Command == "encode" {
+		s.Recurse = 0
+	}
+
+	if s.Recurse != 0 && cli.Command == "embed" {
+		if s.Origin == "" {
+			// the origin of a URL source is only known once it is retrieved
+			if !IsURL(s.Source) {
+				return s, cli, &ConfigError{"missing parameter", "-origin", nil}
+			}
+		}
+	}
+
+	// kept as given, so archives record the address of the page itself
+	if s.Origin != "" && cli.Command != "encode" {
+		_, err := NormalizeOrigin(s.Origin)
+		if err != nil {
+			return s, cli, err
+		}
+	}
+
+	if a.cores != 0 {
+		if a.cores < 1 {
+			return s, cli, &ConfigError{"invalid number of processes", strconv.Itoa(a.cores), nil}
+		}
+
+		cli.Cores = a.cores
+	}
+
+	if a.retries < 1 && cli.Command != "completion" {
+		return s, cli, &ConfigError{"invalid number of attempts", strconv.Itoa(a.retries), nil}
+	}
+
+	if a.retry_delay < 0 {
+		return s, cli, &ConfigError{"invalid retry delay", strconv.Itoa(a.retry_delay), nil}
+	}
+
+	if a.retry_max_delay < 0 {
+		return s, cli, &ConfigError{"invalid maximum retry delay", strconv.Itoa(a.retry_max_delay), nil}
+	}
+
+	s.Retry.Attempts = a.retries
+	s.Retry.Delay = time.Duration(a.retry_delay) * time.Millisecond
+	s.Retry.MaxDelay = time.Duration(a.retry_max_delay) * time.Millisecond
+
+	return s, cli, nil
+}
@@ -0,0 +1,306 @@
+package session
+
+/*
+*	
+*	Config files
+*	
+*	Reads default flag values from a TOML, YAML or JSON file. Keys are flag
+*	names, tables named after a command only apply to that command and the
+*	tables under profiles are applied on top when selected with -profile.
+*	
+*/
+
+import(
+	"os"
+	"flag"
+	"sort"
+	"strconv"
+	"strings"
+	"io/ioutil"
+	"encoding/json"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+	"github.com/BurntSushi/toml"
+)
+
+// Returns the values of -config and -profile in args, which are needed
+// before the other flags can be parsed, and whether -config was given.
+func configArgs(args []string) (string, bool, string) {
+	values := map[string]string{}
+	found := map[string]bool{}
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			break
+		}
+
+		name := strings.TrimLeft(args[i], "-")
+		if name == args[i] {
+			continue
+		}
+
+		parts := strings.SplitN(name, "=", 2)
+		if parts[0] != "config" && parts[0] != "profile" {
+			continue
+		}
+
+		if len(parts) == 2 {
+			values[parts[0]] = parts[1]
+		} else if i + 1 < len(args) {
+			values[parts[0]] = args[i + 1]
+			i++
+		}
+
+		found[parts[0]] = true
+	}
+
+	return values["config"], found["config"], values["profile"]
+}
+
+// Returns the path of the config file to read: ./epoxy.toml or a config
+// file in $XDG_CONFIG_HOME/epoxy, or "" if there is none.
+func findConfig() string {
+	paths := []string{"epoxy.toml"}
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+
+	if dir != "" {
+		for _, name := range []string{"config.toml", "config.yaml", "config.yml", "config.json"} {
+			paths = append(paths, filepath.Join(dir, "epoxy", name))
+		}
+	}
+
+	for i := 0; i < len(paths); i++ {
+		if info, err := os.Stat(paths[i]); err == nil && !info.IsDir() {
+			return paths[i]
+		}
+	}
+
+	return ""
+}
+
+// Decodes the config file at path as JSON or YAML by extension, and as
+// TOML otherwise.
+func ReadConfig(path string) (map[string]interface{}, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, &ConfigError{"invalid config file", path, err}
+	}
+
+	settings := map[string]interface{}{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(body, &settings)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(body, &settings)
+	default:
+		err = toml.Unmarshal(body, &settings)
+	}
+
+	if err != nil {
+		return nil, &ConfigError{"invalid config file", path, err}
+	}
+
+	return settings, nil
+}
+
+// Returns the flag values of a setting, one per element for lists.
+func settingValues(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, true
+	case bool:
+		return []string{strconv.FormatBool(v)}, true
+	case int:
+		return []string{strconv.Itoa(v)}, true
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}, true
+	case uint64:
+		return []string{strconv.FormatUint(v, 10)}, true
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, true
+	case []interface{}:
+		values := []string{}
+
+		for i := 0; i < len(v); i++ {
+			if _, ok := v[i].([]interface{}); ok {
+				return nil, false
+			}
+
+			value, ok := settingValues(v[i])
+			if !ok {
+				return nil, false
+			}
+
+			values = append(values, value...)
+		}
+
+		return values, true
+	}
+
+	return nil, false
+}
+
+// Returns true if a flag called name is defined by any command.
+func isFlag(name string) bool {
+	for i := 0; i < len(commands); i++ {
+		for g := 0; g < len(commands[i].groups); g++ {
+			flags := groupFlags(commands[i], commands[i].groups[g])
+
+			for f := 0; f < len(flags); f++ {
+				if flags[f].Name == name {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns the keys of settings in the order they are applied: rejected
+// file types first, so that accept narrows a broad reject as it does on
+// the command line, then in alphabetical order.
+func settingKeys(settings map[string]interface{}) []string {
+	keys := []string{}
+	for key := range settings {
+		keys = append(keys, key)
+	}
+
+	rejects := func(key string) bool {
+		return key == "reject" || strings.HasPrefix(key, "no-")
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if rejects(keys[i]) != rejects(keys[j]) {
+			return rejects(keys[i])
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+// Settings whose values differ between commands, which only apply to the
+// command named here unless they are set in the table of a command.
+var commandSettings = map[string]string {
+	"format": "embed",
+}
+
+// Returns the names of the flags of command that are set in args.
+func givenFlags(command string, args []string) map[string]bool {
+	c, _ := lookupCommand(command)
+
+	// parsed again for real once the config file is applied
+	fs := newFlagSet(c, &SessionConfig{Options: DefaultOptions()}, &arguments{})
+	parseArgs(fs, args)
+
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	return given
+}
+
+// Sets the flags of fs to the values in settings, followed by those in
+// the table of command. Flags of other commands and flags in given, which
+// are set on the command line, are ignored, so a list on the command line
+// replaces the one in the file instead of adding to it. scoped is true for
+// the table of command itself.
+func applySettings(fs *flag.FlagSet, command string, settings map[string]interface{}, path string, given map[string]bool, scoped bool) error {
+	keys := settingKeys(settings)
+
+	for i := 0; i < len(keys); i++ {
+		key := keys[i]
+
+		if _, ok := lookupCommand(key); ok || key == "profiles" || given[key] {
+			continue
+		}
+
+		if only, ok := commandSettings[key]; ok && !scoped && only != command {
+			continue
+		}
+
+		if fs.Lookup(key) == nil {
+			if isFlag(key) {
+				continue
+			}
+
+			return &ConfigError{"unknown setting", path + ": " + key, nil}
+		}
+
+		values, ok := settingValues(settings[key])
+		if !ok {
+			return &ConfigError{"invalid setting", path + ": " + key, nil}
+		}
+
+		for v := 0; v < len(values); v++ {
+			err := fs.Set(key, values[v])
+			if err != nil {
+				return &ConfigError{"invalid setting", path + ": " + key, err}
+			}
+		}
+	}
+
+	if table, ok := settings[command]; ok {
+		settings, ok := table.(map[string]interface{})
+		if !ok {
+			return &ConfigError{"invalid setting", path + ": " + command, nil}
+		}
+
+		return applySettings(fs, command, settings, path + ": " + command, given, true)
+	}
+
+	return nil
+}
+
+// Applies the config file given with -config or found in the default
+// locations to the flags of command in fs, along with the profile given
+// with -profile. Flags that are set in args are left to the command line,
+// which is parsed afterwards.
+func applyConfig(fs *flag.FlagSet, command string, args []string) error {
+	path, given, profile := configArgs(args)
+
+	if !given {
+		path = findConfig()
+	}
+
+	if path == "" {
+		if profile != "" {
+			return &ConfigError{"no config file for profile", profile, nil}
+		}
+
+		return nil
+	}
+
+	settings, err := ReadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	overridden := givenFlags(command, args)
+
+	err = applySettings(fs, command, settings, path, overridden, false)
+	if err != nil || profile == "" {
+		return err
+	}
+
+	profiles, _ := settings["profiles"].(map[string]interface{})
+
+	selected, ok := profiles[profile].(map[string]interface{})
+	if !ok {
+		return &ConfigError{"unknown profile", profile, nil}
+	}
+
+	return applySettings(fs, command, selected, path + ": profiles." + profile, overridden, false)
+}
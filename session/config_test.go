@@ -0,0 +1,171 @@
+package session
+
+import(
+	"os"
+	"reflect"
+	"testing"
+	"path/filepath"
+)
+
+func TestSettingKeys(t *testing.T) {
+	settings := map[string]interface{}{"retries": 5, "accept": "image/*", "no-png": true, "reject": "*/*", "deny": "host:a"}
+
+	keys := settingKeys(settings)
+	expected := []string{"no-png", "reject", "accept", "deny", "retries"}
+
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("settingKeys() = %v, expected %v", keys, expected)
+	}
+}
+
+func TestApplySettings(t *testing.T) {
+	settings := map[string]interface{} {
+		"retries": int64(5),
+		"format": "mhtml",
+		"header": []interface{}{"A: 1", "B: 2"},
+		"extract": map[string]interface{}{"retries": int64(7)},
+	}
+
+	tests := []struct {
+		command string
+		given map[string]bool
+		retries int
+		format string
+		headers []string
+	}{
+		{"embed", nil, 5, "mhtml", []string{"A: 1", "B: 2"}},
+		{"embed", map[string]bool{"retries": true, "header": true}, 3, "mhtml", nil},
+		{"extract", nil, 7, "dir", []string{"A: 1", "B: 2"}},
+	}
+
+	for _, test := range tests {
+		c, _ := lookupCommand(test.command)
+		a := &arguments{}
+		fs := newFlagSet(c, &SessionConfig{Options: DefaultOptions()}, a)
+
+		err := applySettings(fs, test.command, settings, "config.toml", test.given, false)
+		if err != nil {
+			t.Fatalf("%s: applySettings() returned %v", test.command, err)
+		}
+
+		if a.retries != test.retries || a.format != test.format || !reflect.DeepEqual(a.headers, test.headers) {
+			t.Errorf("%s: got retries %d, format %q, headers %v, expected %d, %q, %v", test.command, a.retries, a.format, a.headers, test.retries, test.format, test.headers)
+		}
+	}
+}
+
+func TestApplySettingsUnknown(t *testing.T) {
+	c, _ := lookupCommand("embed")
+	fs := newFlagSet(c, &SessionConfig{Options: DefaultOptions()}, &arguments{})
+
+	err := applySettings(fs, "embed", map[string]interface{}{"no-such-flag": true}, "config.toml", nil, false)
+	if err == nil {
+		t.Errorf("applySettings() accepted an unknown setting")
+	}
+
+	// flags of other commands are ignored
+	c, _ = lookupCommand("inspect")
+	fs = newFlagSet(c, &SessionConfig{Options: DefaultOptions()}, &arguments{})
+
+	err = applySettings(fs, "inspect", map[string]interface{}{"recurse": int64(2)}, "config.toml", nil, false)
+	if err != nil {
+		t.Errorf("applySettings() returned %v for a flag of another command", err)
+	}
+}
+
+func writeConfig(t *testing.T, config string) string {
+	path := filepath.Join(t.TempDir(), "epoxy.toml")
+
+	err := os.WriteFile(path, []byte(config), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestApplyConfig(t *testing.T) {
+	path := writeConfig(t, `
+retries = 5
+
+[embed]
+recurse = 2
+
+[profiles.small]
+retries = 6
+max-resource-size = "1M"
+`)
+
+	tests := []struct {
+		args []string
+		retries int
+		recurse int
+		max_resource_size string
+		valid bool
+	}{
+		{[]string{"-config", path}, 5, 2, "", true},
+		{[]string{"-config=" + path, "-profile", "small"}, 6, 2, "1M", true},
+		{[]string{"-config", path, "-profile", "large"}, 0, 0, "", false},
+		{[]string{"-config", filepath.Join(filepath.Dir(path), "missing.toml")}, 0, 0, "", false},
+	}
+
+	for _, test := range tests {
+		c, _ := lookupCommand("embed")
+		s := &SessionConfig{Options: DefaultOptions()}
+		a := &arguments{}
+		fs := newFlagSet(c, s, a)
+
+		err := applyConfig(fs, "embed", test.args)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: applyConfig() returned %v", test.args, err)
+			continue
+		}
+
+		if test.valid && (a.retries != test.retries || s.Recurse != test.recurse || a.max_resource_size != test.max_resource_size) {
+			t.Errorf("%v: got retries %d, recurse %d, max-resource-size %q", test.args, a.retries, s.Recurse, a.max_resource_size)
+		}
+	}
+}
+
+func TestNewSessionConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+retries = 5
+deny = ["regex:."]
+header = ["B: 2"]
+format = "mhtml"
+
+[profiles.small]
+max-resource-size = "1M"
+`)
+
+	tests := []struct {
+		args []string
+		retries int
+		allowed bool
+		headers []string
+		format string
+		limit int64
+	}{
+		{[]string{"-config", path, "https://example.com/"}, 5, false, []string{"2"}, "mhtml", 0},
+		{[]string{"-config", path, "-retries", "2", "-allow", "host:example.com", "-header", "A: 1", "https://example.com/"}, 2, true, nil, "mhtml", 0},
+		{[]string{"-config", path, "-profile", "small", "-format", "html", "https://example.com/"}, 5, false, []string{"2"}, "html", 1 << 20},
+		{[]string{"extract", "-config", path, "page.html"}, 5, true, []string{"2"}, "dir", 0},
+	}
+
+	for _, test := range tests {
+		s, cli, err := NewSession(test.args)
+		if err != nil {
+			t.Fatalf("%v: NewSession() returned %v", test.args, err)
+		}
+
+		allowed := s.Rules.Match("https://example.com/a.png") == nil || s.Rules.Match("https://example.com/a.png").Allow
+		if cli.Command == "extract" {
+			// rules only apply to embed
+			allowed = len(s.Rules) == 0
+		}
+
+		if s.Retry.Attempts != test.retries || allowed != test.allowed || !reflect.DeepEqual(s.Headers["B"], test.headers) || cli.Format != test.format || s.Limits.Resource != test.limit {
+			t.Errorf("%v: got retries %d, allowed %t, header B %v, format %q, limit %d", test.args, s.Retry.Attempts, allowed, s.Headers["B"], cli.Format, s.Limits.Resource)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package session
+
+/*
+*	
+*	Errors returned by NewSession.
+*	
+*/
+
+import(
+	"errors"
+)
+
+var (
+	ErrHelp = errors.New("help requested")
+	ErrConfig = errors.New("invalid configuration")
+)
+
+// Returned when the command line cannot be turned into a session.
+type ConfigError struct {
+	Reason string
+	Value string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	str := e.Reason
+	if e.Value != "" {
+		str += ": " + e.Value
+	}
+	if e.Err != nil {
+		str += " (" + e.Err.Error() + ")"
+	}
+
+	return str
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrConfig
+}
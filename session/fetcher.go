@@ -0,0 +1,27 @@
+package session
+
+/*
+*	
+*	Resource retrieval
+*	
+*/
+
+// Retrieves the resource at address on behalf of the document s, returning
+// its body and Content-Type.
+type Fetcher interface {
+	Fetch(address string, s *SessionConfig) ([]byte, string, error)
+}
+
+// Adapts a function with the signature of net.SendRequest to a Fetcher.
+type FetcherFunc func(address string, s *SessionConfig) ([]byte, string, error)
+
+func (f FetcherFunc) Fetch(address string, s *SessionConfig) ([]byte, string, error) {
+	return f(address, s)
+}
+
+// Implemented by fetchers that follow redirects, returning the address the
+// body was eventually retrieved from along with it.
+type LocationFetcher interface {
+	Fetcher
+	FetchLocation(address string, s *SessionConfig) ([]byte, string, string, error)
+}
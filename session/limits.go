@@ -0,0 +1,89 @@
+package session
+
+/*
+*	
+*	Size limits
+*	
+*	Limits the size of single resources, by MIME type if need be, and of
+*	every resource of a run together.
+*	
+*/
+
+import(
+	"strconv"
+	"strings"
+)
+
+// What happens to references to resources that exceed a limit.
+const (
+	OversizeSkip = "skip"
+	OversizeLink = "link"
+	OversizePlaceholder = "placeholder"
+)
+
+type Limits struct {
+	Resource int64              // bytes per resource, 0 for no limit
+	Total int64                 // bytes retrieved during a run
+	Classes map[string]int64    // bytes per resource by MIME type pattern, e.g. video/*
+	Oversize string
+}
+
+// Returns the number of bytes a resource of mimetype may have, given what
+// is left of the total, or 0 if there is no limit. The most specific
+// pattern in Classes takes precedence over Resource.
+func (o *Options) MaxSize(mimetype string) int64 {
+	limit := o.Limits.Resource
+
+	match := -1
+	for pattern, size := range o.Limits.Classes {
+		if m := matchMimetype(pattern, mimetype); m > match {
+			match = m
+			limit = size
+		}
+	}
+
+	if o.Limits.Total > 0 {
+		remaining := o.Limits.Total
+		if o.Summary != nil {
+			remaining -= o.Summary.Size()
+		}
+
+		if remaining < 1 {
+			remaining = 1
+		}
+
+		if limit == 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+
+	return limit
+}
+
+// Parses a number of bytes with an optional K, M or G suffix, e.g. 10M.
+func ParseSize(value string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(value))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+
+	multiplier := int64(1)
+
+	switch {
+	case strings.HasSuffix(str, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(str, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(str, "G"):
+		multiplier = 1 << 30
+	}
+
+	if multiplier > 1 {
+		str = str[:len(str) - 1]
+	}
+
+	size, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || size < 0 {
+		return 0, &ConfigError{"invalid size", value, err}
+	}
+
+	return size * multiplier, nil
+}
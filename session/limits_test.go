@@ -0,0 +1,63 @@
+package session
+
+import(
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		size int64
+		valid bool
+	}{
+		{"0", 0, true},
+		{"512", 512, true},
+		{"10K", 10 << 10, true},
+		{"10m", 10 << 20, true},
+		{" 2G ", 2 << 30, true},
+		{"5MB", 5 << 20, true},
+		{"5MiB", 5 << 20, true},
+		{"", 0, false},
+		{"M", 0, false},
+		{"-1", 0, false},
+		{"1.5M", 0, false},
+		{"10T", 0, false},
+	}
+
+	for _, test := range tests {
+		size, err := ParseSize(test.value)
+		if (err == nil) != test.valid || size != test.size {
+			t.Errorf("ParseSize(%q) = %d, %v, expected %d", test.value, size, err, test.size)
+		}
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	tests := []struct {
+		limits Limits
+		retrieved int64
+		mimetype string
+		max int64
+	}{
+		{Limits{0, 0, map[string]int64{}, OversizeSkip}, 0, "image/png", 0},
+		{Limits{100, 0, map[string]int64{}, OversizeSkip}, 0, "image/png", 100},
+		{Limits{100, 0, map[string]int64{"image/*": 50}, OversizeSkip}, 0, "image/png", 50},
+		{Limits{100, 0, map[string]int64{"image/*": 50, "image/png": 70}, OversizeSkip}, 0, "image/png", 70},
+		{Limits{100, 0, map[string]int64{"image/*": 50}, OversizeSkip}, 0, "text/css", 100},
+		{Limits{100, 0, map[string]int64{"*/*": 10}, OversizeSkip}, 0, "text/css", 10},
+		{Limits{0, 1000, map[string]int64{}, OversizeSkip}, 400, "text/css", 600},
+		{Limits{100, 1000, map[string]int64{}, OversizeSkip}, 400, "text/css", 100},
+		{Limits{100, 1000, map[string]int64{}, OversizeSkip}, 950, "text/css", 50},
+		{Limits{0, 1000, map[string]int64{}, OversizeSkip}, 1000, "text/css", 1},
+	}
+
+	for _, test := range tests {
+		o := DefaultOptions()
+		o.Limits = test.limits
+		o.Summary.Reserve(test.retrieved, 0)
+
+		if max := o.MaxSize(test.mimetype); max != test.max {
+			t.Errorf("%+v with %d retrieved: MaxSize(%q) = %d, expected %d", test.limits, test.retrieved, test.mimetype, max, test.max)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package session
+
+/*
+*	
+*	Request headers and cookies
+*	
+*	Parses extra request headers and imports cookies into the jar that is
+*	shared by every request of a run.
+*	
+*/
+
+import(
+	"os"
+	"time"
+	"bufio"
+	"strconv"
+	"strings"
+	"net/url"
+	"net/http"
+	"net/http/cookiejar"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Returns an empty cookie jar that keeps cookies per registrable domain.
+func NewCookieJar() http.CookieJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+
+	return jar
+}
+
+// Parses a header written as "Name: Value".
+func ParseHeader(line string) (string, string, error) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.ContainsAny(strings.TrimSpace(parts[0]), " \t") {
+		return "", "", &ConfigError{"invalid header", line, nil}
+	}
+
+	return http.CanonicalHeaderKey(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1]), nil
+}
+
+// Adds the cookies of a Cookie header value, such as "a=1; b=2", to jar
+// for the host of address only.
+func AddCookies(jar http.CookieJar, address string, value string) error {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return &ConfigError{"invalid cookie URL", address, err}
+	}
+
+	cookies, err := http.ParseCookie(value)
+	if err != nil {
+		return &ConfigError{"invalid cookie", value, err}
+	}
+
+	jar.SetCookies(&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}, cookies)
+
+	return nil
+}
+
+// Imports the cookies of a cookies.txt file in the Netscape format, as
+// exported by browser extensions and curl, into jar. Lines are tab
+// separated domain, subdomains flag, path, secure flag, expiry, name and
+// value, #HttpOnly_ prefixes the domain of HTTP only cookies.
+func ReadCookies(file string, jar http.CookieJar) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return &ConfigError{"invalid cookies file", file, err}
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+
+		http_only := strings.HasPrefix(text, "#HttpOnly_")
+		if http_only {
+			text = strings.TrimPrefix(text, "#HttpOnly_")
+		}
+
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return &ConfigError{"invalid cookie", file + ":" + strconv.Itoa(line), nil}
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return &ConfigError{"invalid cookie", file + ":" + strconv.Itoa(line), err}
+		}
+
+		host := strings.TrimPrefix(fields[0], ".")
+		secure := strings.EqualFold(fields[3], "TRUE")
+
+		cookie := &http.Cookie {
+			Name:      fields[5],
+			Value:     fields[6],
+			Path:      fields[2],
+			Secure:    secure,
+			HttpOnly:  http_only,
+		}
+
+		// cookies without the subdomains flag are sent to host only
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = host
+		}
+
+		// 0 marks session cookies
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: fields[2]}, []*http.Cookie{cookie})
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return &ConfigError{"invalid cookies file", file, err}
+	}
+
+	return nil
+}
+
+// Returns the Referer header for a request to address made on behalf of
+// the document s, which is the address of the document unless it is not
+// an HTTP URL, the request is for the document itself, or it would leak
+// an HTTPS address to plain HTTP.
+func (s *SessionConfig) Referer(address string) string {
+	parent, err := url.Parse(s.Source)
+	if err != nil || (parent.Scheme != "http" && parent.Scheme != "https") || s.Source == address {
+		return ""
+	}
+
+	if parent.Scheme == "https" && strings.HasPrefix(strings.ToLower(address), "http:") {
+		return ""
+	}
+
+	parent.Fragment = ""
+	parent.User = nil
+
+	return parent.String()
+}
@@ -0,0 +1,74 @@
+package session
+
+import(
+	"os"
+	"net/url"
+	"testing"
+	"path/filepath"
+)
+
+func TestReadCookies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+
+	cookies := "# Netscape HTTP Cookie File\n" +
+	           "\n" +
+	           ".example.com\tTRUE\t/\tFALSE\t0\tsite\t1\n" +
+	           "www.example.com\tFALSE\t/\tTRUE\t0\tsecure\t2\r\n" +
+	           "#HttpOnly_example.com\tFALSE\t/app\tFALSE\t0\tapp\t3\n" +
+	           "example.com\tFALSE\t/\tFALSE\t1\texpired\t4\n"
+
+	err := os.WriteFile(path, []byte(cookies), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jar := NewCookieJar()
+
+	err = ReadCookies(path, jar)
+	if err != nil {
+		t.Fatalf("ReadCookies() returned %v", err)
+	}
+
+	tests := []struct {
+		address string
+		cookies string
+	}{
+		{"http://example.com/", "site=1"},
+		{"https://www.example.com/", "site=1; secure=2"},
+		{"http://www.example.com/", "site=1"},
+		{"http://example.com/app/page", "app=3; site=1"},
+		{"http://sub.www.example.com/", "site=1"},
+		{"http://example.org/", ""},
+	}
+
+	for _, test := range tests {
+		u, _ := url.Parse(test.address)
+
+		str := ""
+		for _, cookie := range jar.Cookies(u) {
+			if str != "" {
+				str += "; "
+			}
+			str += cookie.Name + "=" + cookie.Value
+		}
+
+		if str != test.cookies {
+			t.Errorf("cookies for %s = %q, expected %q", test.address, str, test.cookies)
+		}
+	}
+}
+
+func TestReadCookiesInvalid(t *testing.T) {
+	for _, line := range []string{"example.com\tFALSE\t/\tFALSE\t0\tname\n", "example.com\tFALSE\t/\tFALSE\tnever\tname\tvalue\n"} {
+		path := filepath.Join(t.TempDir(), "cookies.txt")
+
+		err := os.WriteFile(path, []byte(line), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ReadCookies(path, NewCookieJar()) == nil {
+			t.Errorf("ReadCookies() accepted %q", line)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package session
+
+/*
+*	
+*	URL rules
+*	
+*	Allows or denies resolved URLs by host, host suffix, path glob or
+*	regular expression before they are retrieved.
+*	
+*/
+
+import(
+	"os"
+	"path"
+	"bufio"
+	"regexp"
+	"strings"
+	"net/url"
+	"strconv"
+)
+
+// What happens to references to denied URLs.
+const (
+	DeniedKeep = "keep"
+	DeniedBlank = "blank"
+	DeniedAboutBlank = "about:blank"
+)
+
+type Rule struct {
+	Allow bool
+	Kind string       // host, suffix, path or regex
+	Pattern string
+	regex *regexp.Regexp
+}
+
+// Parses a rule written as kind:pattern, e.g. suffix:doubleclick.net or
+// path:/ads/*.
+func ParseRule(allow bool, spec string) (Rule, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Rule{}, &ConfigError{"invalid rule", spec, nil}
+	}
+
+	rule := Rule{allow, parts[0], parts[1], nil}
+
+	switch rule.Kind {
+	case "host", "suffix":
+		rule.Pattern = strings.ToLower(strings.TrimPrefix(rule.Pattern, "."))
+	case "path":
+		_, err := path.Match(rule.Pattern, "")
+		if err != nil {
+			return Rule{}, &ConfigError{"invalid rule", spec, err}
+		}
+	case "regex":
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return Rule{}, &ConfigError{"invalid rule", spec, err}
+		}
+
+		rule.regex = regex
+	default:
+		return Rule{}, &ConfigError{"invalid rule", spec, nil}
+	}
+
+	return rule, nil
+}
+
+// Reports whether address is matched by the rule. Paths are matched as
+// globs in the sense of path.Match, regular expressions against the whole
+// address.
+func (r *Rule) Matches(address string) bool {
+	if r.Kind == "regex" {
+		return r.regex.MatchString(address)
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	switch r.Kind {
+	case "host":
+		return host == r.Pattern
+	case "suffix":
+		return host == r.Pattern || strings.HasSuffix(host, "." + r.Pattern)
+	case "path":
+		matched, _ := path.Match(r.Pattern, u.EscapedPath())
+		return matched
+	}
+
+	return false
+}
+
+func (r *Rule) String() string {
+	action := "deny"
+	if r.Allow {
+		action = "allow"
+	}
+
+	return action + " " + r.Kind + ":" + r.Pattern
+}
+
+type Rules []Rule
+
+// Returns the first rule that matches address, or nil if none does, in
+// which case address is allowed.
+func (rules Rules) Match(address string) *Rule {
+	for i := 0; i < len(rules); i++ {
+		if rules[i].Matches(address) {
+			return &rules[i]
+		}
+	}
+
+	return nil
+}
+
+// Reads rules from a file with one rule per line, each starting with allow
+// or deny, e.g. "deny suffix:google-analytics.com". Empty lines and lines
+// starting with # are ignored.
+func ReadRules(file string) (Rules, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, &ConfigError{"invalid rules file", file, err}
+	}
+	defer f.Close()
+
+	rules := Rules{}
+	scanner := bufio.NewScanner(f)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 || (fields[0] != "allow" && fields[0] != "deny") {
+			return nil, &ConfigError{"invalid rule", file + ":" + strconv.Itoa(line), nil}
+		}
+
+		rule, err := ParseRule(fields[0] == "allow", fields[1])
+		if err != nil {
+			return nil, &ConfigError{"invalid rule", file + ":" + strconv.Itoa(line), err}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, &ConfigError{"invalid rules file", file, err}
+	}
+
+	return rules, nil
+}
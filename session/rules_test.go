@@ -0,0 +1,85 @@
+package session
+
+import(
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec string
+		kind string
+		pattern string
+		valid bool
+	}{
+		{"host:WWW.Example.com", "host", "www.example.com", true},
+		{"suffix:.example.com", "suffix", "example.com", true},
+		{"path:/ads/*", "path", "/ads/*", true},
+		{"regex:^https?://", "regex", "^https?://", true},
+		{"path:[", "", "", false},
+		{"regex:(", "", "", false},
+		{"domain:example.com", "", "", false},
+		{"host:", "", "", false},
+		{"example.com", "", "", false},
+	}
+
+	for _, test := range tests {
+		rule, err := ParseRule(false, test.spec)
+		if (err == nil) != test.valid {
+			t.Errorf("ParseRule(%q) returned %v", test.spec, err)
+			continue
+		}
+
+		if rule.Kind != test.kind || rule.Pattern != test.pattern {
+			t.Errorf("ParseRule(%q) = %s:%s, expected %s:%s", test.spec, rule.Kind, rule.Pattern, test.kind, test.pattern)
+		}
+	}
+}
+
+func TestRulesMatch(t *testing.T) {
+	rules := Rules{}
+	for _, spec := range []struct {
+		allow bool
+		spec string
+	}{
+		{true, "host:cdn.ads.example"},
+		{false, "suffix:ads.example"},
+		{false, "path:/tracking/*"},
+		{true, "suffix:example.com"},
+		{false, `regex:\.gif$`},
+	} {
+		rule, err := ParseRule(spec.allow, spec.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	tests := []struct {
+		address string
+		rule string
+	}{
+		{"https://cdn.ads.example/a.js", "allow host:cdn.ads.example"},
+		{"https://www.ads.example/a.js", "deny suffix:ads.example"},
+		{"https://ads.example/a.js", "deny suffix:ads.example"},
+		{"https://badads.example/a.js", ""},
+		{"https://example.com/tracking/pixel.gif", "deny path:/tracking/*"},
+		{"https://example.com/tracking/a/pixel.gif", "allow suffix:example.com"},
+		{"https://static.example.com/logo.gif", "allow suffix:example.com"},
+		{"https://example.net/logo.gif", `deny regex:\.gif$`},
+		{"https://example.net/logo.png", ""},
+	}
+
+	for _, test := range tests {
+		rule := rules.Match(test.address)
+
+		matched := ""
+		if rule != nil {
+			matched = rule.String()
+		}
+
+		if matched != test.rule {
+			t.Errorf("Match(%q) = %q, expected %q", test.address, matched, test.rule)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package session
+
+/*
+*	
+*	Scope
+*	
+*	Limits the resources that are retrieved, at every recursion level, to
+*	those of the origin or the site of the root document, or to a list of
+*	hosts.
+*	
+*/
+
+import(
+	"strings"
+	"net/url"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	ScopeAny = "any"
+	ScopeSameOrigin = "same-origin"
+	ScopeSameSite = "same-site"
+	ScopeHosts = "hosts"
+)
+
+type Scope struct {
+	Mode string
+	Hosts []string    // in scope in every mode, a leading dot includes subdomains
+	Origin string     // of the root document, set by Parse
+}
+
+// Returns the registrable domain of host, e.g. example.co.uk for
+// www.example.co.uk, or host itself if it has none, such as localhost.
+func registrableDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+
+	return domain
+}
+
+// Reports whether address is within scope, addresses that cannot be
+// parsed are not.
+func (scope *Scope) Contains(address string) bool {
+	if scope.Mode == "" || scope.Mode == ScopeAny {
+		return true
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	for i := 0; i < len(scope.Hosts); i++ {
+		pattern := strings.ToLower(scope.Hosts[i])
+
+		if host == strings.TrimPrefix(pattern, ".") || (strings.HasPrefix(pattern, ".") && strings.HasSuffix(host, pattern)) {
+			return true
+		}
+	}
+
+	if scope.Mode == ScopeHosts {
+		return false
+	}
+
+	origin, err := url.Parse(scope.Origin)
+	if err != nil {
+		return false
+	}
+
+	origin_host := strings.ToLower(origin.Hostname())
+
+	if scope.Mode == ScopeSameOrigin {
+		return strings.EqualFold(u.Scheme, origin.Scheme) && host == origin_host && u.Port() == origin.Port()
+	}
+
+	return registrableDomain(host) == registrableDomain(origin_host)
+}
@@ -0,0 +1,38 @@
+package session
+
+import(
+	"testing"
+)
+
+func TestScopeContains(t *testing.T) {
+	tests := []struct {
+		mode string
+		hosts []string
+		address string
+		contains bool
+	}{
+		{ScopeAny, nil, "https://elsewhere.net/a.png", true},
+		{"", nil, "https://elsewhere.net/a.png", true},
+		{ScopeSameOrigin, nil, "https://www.example.co.uk/a.png", true},
+		{ScopeSameOrigin, nil, "http://www.example.co.uk/a.png", false},
+		{ScopeSameOrigin, nil, "https://www.example.co.uk:8443/a.png", false},
+		{ScopeSameOrigin, nil, "https://cdn.example.co.uk/a.png", false},
+		{ScopeSameSite, nil, "https://cdn.example.co.uk/a.png", true},
+		{ScopeSameSite, nil, "http://example.co.uk/a.png", true},
+		{ScopeSameSite, nil, "https://other.co.uk/a.png", false},
+		{ScopeSameSite, []string{".cdn.net"}, "https://img.cdn.net/a.png", true},
+		{ScopeSameSite, []string{".cdn.net"}, "https://cdn.net/a.png", true},
+		{ScopeSameSite, []string{"cdn.net"}, "https://img.cdn.net/a.png", false},
+		{ScopeHosts, []string{"static.example.net"}, "https://STATIC.example.net/a.png", true},
+		{ScopeHosts, []string{"static.example.net"}, "https://www.example.co.uk/a.png", false},
+		{ScopeSameOrigin, nil, "%zz", false},
+	}
+
+	for _, test := range tests {
+		scope := Scope{test.mode, test.hosts, "https://www.example.co.uk/index.html"}
+
+		if scope.Contains(test.address) != test.contains {
+			t.Errorf("%s %v: Contains(%q) = %t, expected %t", test.mode, test.hosts, test.address, !test.contains, test.contains)
+		}
+	}
+}
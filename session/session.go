@@ -7,133 +7,271 @@ package session
 */
 
 import(
-	"os"
+	"mime"
 	"sync"
+	"time"
 	"regexp"
-	"strconv"
-	"io/ioutil"
-
-	"github.com/buffermet/epoxy/log"
+	"strings"
+	"net/http"
 )
 
 type Resource struct {
 	Type string
 	Address string
 	Body []byte
+	Placeholder bool
+}
+
+type Failure struct {
+	Address string
+	Err error
+}
+
+// Collects the outcome of a run across every recursion level.
+type Summary struct {
+	sync.Mutex
+	Failures []Failure
+	Denials map[string]int   // number of URLs denied per rule
+	Connections Connections
+	size int64               // bytes retrieved
+}
+
+// Counts how HTTP requests of a run were sent.
+type Connections struct {
+	Opened int      // requests sent over a new connection
+	Reused int      // requests sent over a connection of an earlier request
+	HTTP2 int       // responses received over HTTP/2
+}
+
+func (summary *Summary) AddFailure(address string, err error) {
+	summary.Lock()
+	defer summary.Unlock()
+
+	summary.Failures = append(summary.Failures, Failure{address, err})
+}
+
+// Adds size to the bytes retrieved during the run unless that would exceed
+// total, in which case it reports false. A total of 0 means no limit.
+func (summary *Summary) Reserve(size, total int64) bool {
+	summary.Lock()
+	defer summary.Unlock()
+
+	if total > 0 && summary.size + size > total {
+		return false
+	}
+
+	summary.size += size
+
+	return true
+}
+
+// Returns the number of bytes retrieved during the run.
+func (summary *Summary) Size() int64 {
+	summary.Lock()
+	defer summary.Unlock()
+
+	return summary.size
+}
+
+func (summary *Summary) AddConnection(reused bool) {
+	summary.Lock()
+	defer summary.Unlock()
+
+	if reused {
+		summary.Connections.Reused++
+	} else {
+		summary.Connections.Opened++
+	}
+}
+
+func (summary *Summary) AddHTTP2() {
+	summary.Lock()
+	defer summary.Unlock()
+
+	summary.Connections.HTTP2++
+}
+
+func (summary *Summary) AddDenial(rule string) {
+	summary.Lock()
+	defer summary.Unlock()
+
+	if summary.Denials == nil {
+		summary.Denials = map[string]int{}
+	}
+
+	summary.Denials[rule]++
+}
+
+// An HTTP request and the response it was answered with, as sent and
+// received on the wire apart from transfer and content encodings.
+type Exchange struct {
+	URL string
+	Date time.Time
+	Duration time.Duration
+	RequestHeader []byte     // request line and headers
+	ResponseHeader []byte    // status line and headers
+	Body []byte
+	Cached bool              // answered from the cache, Date is when it was stored
+}
+
+// Collects every HTTP exchange of a run if set in Options.
+type Recorder struct {
+	sync.Mutex
+	Exchanges []Exchange
+}
+
+func (recorder *Recorder) Add(exchange Exchange) {
+	recorder.Lock()
+	defer recorder.Unlock()
+
+	recorder.Exchanges = append(recorder.Exchanges, exchange)
+}
+
+type RetryPolicy struct {
+	Attempts int              // maximum number of attempts per request
+	Delay time.Duration       // backoff before the second attempt
+	MaxDelay time.Duration    // upper limit for backoff and Retry-After
+	Jitter float64            // fraction of each backoff that is randomized
+}
+
+// Settings shared by every document of a run, from the root document down
+// to the deepest nested stylesheet.
+type Options struct {
+	Accept []string
+	Reject []string
+	Retry RetryPolicy
+	Placeholder string
+	Summary *Summary
+	Confirm func(count int) bool
+	Fetcher Fetcher
+	Root string
+	KeepReferences bool
+	Recorder *Recorder
+	Rules Rules
+	Denied string
+	Scope Scope
+	Limits Limits
+	Headers http.Header
+	Jar http.CookieJar
+	Browser string
+	UserAgent string
+	Transport http.RoundTripper
+	Client *http.Client
+	Cache string
+	Offline bool
 }
 
 type SessionConfig struct {
 	Source string
 	Origin string
 	Body []byte
-	Accept []string
 	Recurse int
+	Depth int
 	Resources []Resource
 	RequestQueue sync.WaitGroup
+	ResourcesLock sync.Mutex
+	*Options
 }
 
-var (
-	Cores = 4
-	Depth int
-	Print bool
-)
+func (s *SessionConfig) AddResource(resource Resource) {
+	s.ResourcesLock.Lock()
+	defer s.ResourcesLock.Unlock()
+
+	s.Resources = append(s.Resources, resource)
+}
+
+var selectorMimetypeParameters = regexp.MustCompile(`\s*;.*$`)
+
+// Returns how specifically pattern matches mimetype: 2 for the type
+// itself, 1 for type/* and 0 for */*, or -1 if it doesn't match at all.
+// Parameters such as charset are ignored.
+func matchMimetype(pattern, mimetype string) int {
+	pattern = strings.ToLower(selectorMimetypeParameters.ReplaceAllString(pattern, ""))
+	mimetype = strings.ToLower(selectorMimetypeParameters.ReplaceAllString(mimetype, ""))
+
+	if pattern == mimetype {
+		return 2
+	} else if pattern == "*" || pattern == "*/*" {
+		return 0
+	} else if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mimetype, strings.TrimSuffix(pattern, "*")) {
+		return 1
+	}
+
+	return -1
+}
+
+// Reports whether resources of mimetype may be embedded. The most specific
+// pattern in Accept and Reject decides, Reject wins if both are equally
+// specific, so image/png can be rejected while image/* is accepted and
+// vice versa.
+func (o *Options) Accepts(mimetype string) bool {
+	accept := -1
+	for i := 0; i < len(o.Accept); i++ {
+		if match := matchMimetype(o.Accept[i], mimetype); match > accept {
+			accept = match
+		}
+	}
+
+	reject := -1
+	for i := 0; i < len(o.Reject); i++ {
+		if match := matchMimetype(o.Reject[i], mimetype); match > reject {
+			reject = match
+		}
+	}
+
+	return accept > reject
+}
+
+// Removes the patterns matched by any of patterns from Accept and adds
+// patterns to Reject, so types accepted earlier, such as image/png by
+// DefaultAccept, are rejected by image/* while types accepted later by a
+// more specific pattern are not.
+func (o *Options) RejectMimetypes(patterns []string) {
+	accept := []string{}
+
+	for i := 0; i < len(o.Accept); i++ {
+		rejected := false
+		for a := 0; a < len(patterns); a++ {
+			rejected = rejected || matchMimetype(patterns[a], o.Accept[i]) >= 0
+		}
+
+		if !rejected {
+			accept = append(accept, o.Accept[i])
+		}
+	}
 
-func showOptions() {
-	str := "usage: epoxy <options> -source <path> -origin <url>\n" + 
-	       "\n" + 
-	       "Options:\n" + 
-	       "\n" + 
-	       "  -print          print payload to stdout.\n" + 
-	       "\n" + 
-	       "  -source PATH    path to source file.\n" + 
-	       "  -origin URL     full URL to source file.\n" + 
-	       "\n" + 
-	       "  -recurse INT    limit of recursions for resource embedding (default=1).\n" + 
-	       "  -cores INT      limit of procs for async parsing (default=4).\n" + 
-	       "\n" + 
-	       "  -no-unknown     don't embed unknown filetypes.\n" + 
-	       "  -no-svg         don't embed svg files.\n" + 
-	       "  -no-jpg         don't embed jpg files.\n" + 
-	       "  -no-png         don't embed png files.\n" + 
-	       "  -no-gif         don't embed gif files.\n" + 
-	       "  -no-webp        don't embed webp files.\n" + 
-	       "  -no-cr2         don't embed cr2 files.\n" + 
-	       "  -no-tif         don't embed tif files.\n" + 
-	       "  -no-bmp         don't embed bmp files.\n" + 
-	       "  -no-jxr         don't embed jxr files.\n" + 
-	       "  -no-psd         don't embed psd files.\n" + 
-	       "  -no-ico         don't embed ico files.\n" + 
-	       "  -no-mp4         don't embed mp4 files.\n" + 
-	       "  -no-m4v         don't embed m4v files.\n" + 
-	       "  -no-mkv         don't embed mkv files.\n" + 
-	       "  -no-webm        don't embed webm files.\n" + 
-	       "  -no-mov         don't embed mov files.\n" + 
-	       "  -no-avi         don't embed avi files.\n" + 
-	       "  -no-wmv         don't embed wmv files.\n" + 
-	       "  -no-mpg         don't embed mpg files.\n" + 
-	       "  -no-flv         don't embed flv files.\n" + 
-	       "  -no-mid         don't embed mid files.\n" + 
-	       "  -no-mp3         don't embed mp3 files.\n" + 
-	       "  -no-m4a         don't embed m4a files.\n" + 
-	       "  -no-ogg         don't embed ogg files.\n" + 
-	       "  -no-flac        don't embed flac files.\n" + 
-	       "  -no-wav         don't embed wav files.\n" + 
-	       "  -no-amr         don't embed amr files.\n" + 
-	       "  -no-epub        don't embed epub files.\n" + 
-	       "  -no-zip         don't embed zip files.\n" + 
-	       "  -no-tar         don't embed tar files.\n" + 
-	       "  -no-rar         don't embed rar files.\n" + 
-	       "  -no-gz          don't embed gz files.\n" + 
-	       "  -no-bz2         don't embed bz2 files.\n" + 
-	       "  -no-7z          don't embed 7z files.\n" + 
-	       "  -no-xz          don't embed xz files.\n" + 
-	       "  -no-pdf         don't embed pdf files.\n" + 
-	       "  -no-exe         don't embed exe files.\n" + 
-	       "  -no-swf         don't embed swf files.\n" + 
-	       "  -no-rtf         don't embed rtf files.\n" + 
-	       "  -no-eot         don't embed eot files.\n" + 
-	       "  -no-ps          don't embed ps files.\n" + 
-	       "  -no-sqlite      don't embed sqlite files.\n" + 
-	       "  -no-nes         don't embed nes files.\n" + 
-	       "  -no-crx         don't embed crx files.\n" + 
-	       "  -no-cab         don't embed cab files.\n" + 
-	       "  -no-deb         don't embed deb files.\n" + 
-	       "  -no-ar          don't embed ar files.\n" + 
-	       "  -no-z           don't embed z files.\n" + 
-	       "  -no-lz          don't embed lz files.\n" + 
-	       "  -no-rpm         don't embed rpm files.\n" + 
-	       "  -no-elf         don't embed elf files.\n" + 
-	       "  -no-doc         don't embed doc files.\n" + 
-	       "  -no-docx        don't embed docx files.\n" + 
-	       "  -no-xls         don't embed xls files.\n" + 
-	       "  -no-xlsx        don't embed xlsx files.\n" + 
-	       "  -no-ppt         don't embed ppt files.\n" + 
-	       "  -no-pptx        don't embed pptx files.\n" + 
-	       "  -no-woff        don't embed woff files.\n" + 
-	       "  -no-woff2       don't embed woff2 files.\n" + 
-	       "  -no-ttf         don't embed ttf files.\n" + 
-	       "  -no-otf         don't embed otf files.\n" + 
-	       "  -no-css         don't embed css files.\n" + 
-	       "  -no-html        don't embed html files.\n" + 
-	       "  -no-js          don't embed js files.\n" + 
-	       "  -no-json        don't embed json files.\n"
-
-	log.Raw(str)
-
-	os.Exit(0)
+	o.Accept = accept
+	o.Reject = append(o.Reject, patterns...)
 }
 
-func skipMimetype(mimetype string, session *SessionConfig) {
-	for i := 0; i < len(session.Accept); i++ {
-		if mimetype == session.Accept[i] {
-			session.Accept = append(session.Accept[:i], session.Accept[i+1:]...)
-			log.Info("skipping file type: " + mimetype)
+// Turns a comma separated list of MIME types, wildcards such as image/*
+// and file extensions such as .avif into MIME type patterns.
+func ParseMimetypes(list string) ([]string, error) {
+	mimetypes := []string{}
+
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+
+		if item == "" {
+			continue
+		} else if strings.Contains(item, "/") || item == "*" || item == "unknown" {
+			mimetypes = append(mimetypes, item)
+			continue
+		}
+
+		mimetype := mime.TypeByExtension("." + strings.TrimPrefix(item, "."))
+		if mimetype == "" {
+			return mimetypes, &ConfigError{"unknown file extension", item, nil}
 		}
+
+		mimetypes = append(mimetypes, selectorMimetypeParameters.ReplaceAllString(mimetype, ""))
 	}
+
+	return mimetypes, nil
 }
 
-func NewSession() SessionConfig {
-	accept := []string { 
+func DefaultAccept() []string {
+	return []string { 
 		"unknown", 
 		"application/octet-stream", 
 		"image/svg", 
@@ -214,262 +352,70 @@ func NewSession() SessionConfig {
 		"text/json", 
 		"application/json", 
 	}
+}
 
-	s := SessionConfig { 
-		"",                // Source string
-		"",                // Origin string
-		[]byte(""),        // Body []byte
-		accept,            // Accept []string
-		1,                 // Recurse int
-		[]Resource{},      // Resources []Resource
-		sync.WaitGroup{},  // RequestQueue sync.WaitGroup
-	}
-
-	args := os.Args[1:]
-	recurse_arg := ""
-	cores_arg := ""
-
-	for i := 0; i < len(args); i++ {
-		if args[i] == "--help" || args[i] == "-help" {
-			showOptions()
-		} else if args[i] == "--print" || args[i] == "-print" {
-			Print = true
-		} else if args[i] == "--source" || args[i] == "-source" {
-			if i < (len(args) - 1) {
-				s.Source = args[i+1]
-				i++
-			} else {
-				log.Error("missing value for: " + args[i] + "\n")
-				showOptions()
-			}
-		} else if args[i] == "--origin" || args[i] == "-origin" {
-			if i < (len(args) - 1) {
-				s.Origin = args[i+1]
-				i++
-			} else {
-				log.Error("missing value for: " + args[i] + "\n")
-				showOptions()
-			}
-		} else if args[i] == "--recurse" || args[i] == "-recurse" {
-			if i < (len(args) - 1) {
-				recurse_arg = args[i+1]
-				i++
-			} else {
-				log.Error("missing value for: " + args[i] + "\n")
-				showOptions()
-			}
-		} else if args[i] == "--cores" || args[i] == "-cores" {
-			if i < (len(args) - 1) {
-				cores_arg = args[i+1]
-				i++
-			} else {
-				log.Error("missing value for: " + args[i] + "\n")
-				showOptions()
-			}
-		} else if args[i] == "--no-unknown" || args[i] == "-no-unknown" {
-			skipMimetype("unknown", &s)
-			skipMimetype("application/octet-stream", &s)
-		} else if args[i] == "--no-svg" || args[i] == "-no-svg" {
-			skipMimetype("image/svg+xml", &s)
-			skipMimetype("image/svg", &s)
-		} else if args[i] == "--no-jpg" || args[i] == "-no-jpg" {
-			skipMimetype("image/jpeg", &s)
-		} else if args[i] == "--no-png" || args[i] == "-no-png" {
-			skipMimetype("image/png", &s)
-		} else if args[i] == "--no-gif" || args[i] == "-no-gif" {
-			skipMimetype("image/gif", &s)
-		} else if args[i] == "--no-webp" || args[i] == "-no-webp" {
-			skipMimetype("image/webp", &s)
-		} else if args[i] == "--no-cr2" || args[i] == "-no-cr2" {
-			skipMimetype("image/x-canon-cr2", &s)
-		} else if args[i] == "--no-tif" || args[i] == "-no-tif" {
-			skipMimetype("image/tiff", &s)
-		} else if args[i] == "--no-bmp" || args[i] == "-no-bmp" {
-			skipMimetype("image/bmp", &s)
-		} else if args[i] == "--no-jxr" || args[i] == "-no-jxr" {
-			skipMimetype("image/vnd.ms-photo", &s)
-		} else if args[i] == "--no-psd" || args[i] == "-no-psd" {
-			skipMimetype("image/vnd.adobe.photoshop", &s)
-		} else if args[i] == "--no-ico" || args[i] == "-no-ico" {
-			skipMimetype("image/vnd.microsoft.icon", &s)
-			skipMimetype("image/x-icon", &s)
-		} else if args[i] == "--no-mp4" || args[i] == "-no-mp4" {
-			skipMimetype("video/mp4", &s)
-		} else if args[i] == "--no-m4v" || args[i] == "-no-m4v" {
-			skipMimetype("video/x-m4v", &s)
-		} else if args[i] == "--no-mkv" || args[i] == "-no-mkv" {
-			skipMimetype("video/x-matroska", &s)
-		} else if args[i] == "--no-webm" || args[i] == "-no-webm" {
-			skipMimetype("video/webm", &s)
-		} else if args[i] == "--no-mov" || args[i] == "-no-mov" {
-			skipMimetype("video/quicktime", &s)
-		} else if args[i] == "--no-avi" || args[i] == "-no-avi" {
-			skipMimetype("video/x-msvideo", &s)
-		} else if args[i] == "--no-wmv" || args[i] == "-no-wmv" {
-			skipMimetype("video/x-ms-wmv", &s)
-		} else if args[i] == "--no-mpg" || args[i] == "-no-mpg" {
-			skipMimetype("video/mpeg", &s)
-		} else if args[i] == "--no-flv" || args[i] == "-no-flv" {
-			skipMimetype("video/x-flv", &s)
-		} else if args[i] == "--no-mid" || args[i] == "-no-mid" {
-			skipMimetype("audio/midi", &s)
-		} else if args[i] == "--no-mp3" || args[i] == "-no-mp3" {
-			skipMimetype("audio/mpeg", &s)
-		} else if args[i] == "--no-m4a" || args[i] == "-no-m4a" {
-			skipMimetype("audio/m4a", &s)
-		} else if args[i] == "--no-ogg" || args[i] == "-no-ogg" {
-			skipMimetype("audio/ogg", &s)
-		} else if args[i] == "--no-flac" || args[i] == "-no-flac" {
-			skipMimetype("audio/x-flac", &s)
-		} else if args[i] == "--no-wav" || args[i] == "-no-wav" {
-			skipMimetype("audio/x-wav", &s)
-		} else if args[i] == "--no-amr" || args[i] == "-no-amr" {
-			skipMimetype("audio/amr", &s)
-		} else if args[i] == "--no-epub" || args[i] == "-no-epub" {
-			skipMimetype("application/epub+zip", &s)
-		} else if args[i] == "--no-zip" || args[i] == "-no-zip" {
-			skipMimetype("application/zip", &s)
-		} else if args[i] == "--no-tar" || args[i] == "-no-tar" {
-			skipMimetype("application/x-tar", &s)
-		} else if args[i] == "--no-rar" || args[i] == "-no-rar" {
-			skipMimetype("application/x-rar-compressed", &s)
-		} else if args[i] == "--no-gz" || args[i] == "-no-gz" {
-			skipMimetype("application/gzip", &s)
-		} else if args[i] == "--no-bz2" || args[i] == "-no-bz2" {
-			skipMimetype("application/x-bzip2", &s)
-		} else if args[i] == "--no-7z" || args[i] == "-no-7z" {
-			skipMimetype("application/x-7z-compressed", &s)
-		} else if args[i] == "--no-xz" || args[i] == "-no-xz" {
-			skipMimetype("application/x-xz", &s)
-		} else if args[i] == "--no-pdf" || args[i] == "-no-pdf" {
-			skipMimetype("application/pdf", &s)
-		} else if args[i] == "--no-exe" || args[i] == "-no-exe" {
-			skipMimetype("application/x-msdownload", &s)
-		} else if args[i] == "--no-swf" || args[i] == "-no-swf" {
-			skipMimetype("application/x-shockwave-flash", &s)
-		} else if args[i] == "--no-rtf" || args[i] == "-no-rtf" {
-			skipMimetype("application/rtf", &s)
-		} else if args[i] == "--no-eot" || args[i] == "-no-eot" {
-			skipMimetype("application/vnd.ms-fontobject", &s)
-			skipMimetype("font/eot", &s)
-		} else if args[i] == "--no-ps" || args[i] == "-no-ps" {
-			skipMimetype("application/postscript", &s)
-		} else if args[i] == "--no-sqlite" || args[i] == "-no-sqlite" {
-			skipMimetype("application/x-sqlite3", &s)
-		} else if args[i] == "--no-nes" || args[i] == "-no-nes" {
-			skipMimetype("application/x-nintendo-nes-rom", &s)
-		} else if args[i] == "--no-crx" || args[i] == "-no-crx" {
-			skipMimetype("application/x-google-chrome-extension", &s)
-		} else if args[i] == "--no-cab" || args[i] == "-no-cab" {
-			skipMimetype("application/vnd.ms-cab-compressed", &s)
-		} else if args[i] == "--no-deb" || args[i] == "-no-deb" {
-			skipMimetype("application/x-deb", &s)
-		} else if args[i] == "--no-ar" || args[i] == "-no-ar" {
-			skipMimetype("application/x-unix-archive", &s)
-		} else if args[i] == "--no-z" || args[i] == "-no-z" {
-			skipMimetype("application/x-compress", &s)
-		} else if args[i] == "--no-lz" || args[i] == "-no-lz" {
-			skipMimetype("application/x-lzip", &s)
-		} else if args[i] == "--no-rpm" || args[i] == "-no-rpm" {
-			skipMimetype("application/x-rpm", &s)
-		} else if args[i] == "--no-elf" || args[i] == "-no-elf" {
-			skipMimetype("application/x-executable", &s)
-		} else if args[i] == "--no-doc" || args[i] == "-no-doc" {
-			skipMimetype("application/msword", &s)
-		} else if args[i] == "--no-docx" || args[i] == "-no-docx" {
-			skipMimetype("application/vnd.openxmlformats-officedocument.wordprocessingml.document", &s)
-		} else if args[i] == "--no-xls" || args[i] == "-no-xls" {
-			skipMimetype("application/vnd.ms-excel", &s)
-		} else if args[i] == "--no-xlsx" || args[i] == "-no-xlsx" {
-			skipMimetype("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", &s)
-		} else if args[i] == "--no-ppt" || args[i] == "-no-ppt" {
-			skipMimetype("application/vnd.ms-powerpoint", &s)
-		} else if args[i] == "--no-pptx" || args[i] == "-no-pptx" {
-			skipMimetype("application/vnd.openxmlformats-officedocument.presentationml.presentation", &s)
-		} else if args[i] == "--no-woff" || args[i] == "-no-woff" {
-			skipMimetype("application/font-woff", &s)
-			skipMimetype("font/woff", &s)
-		} else if args[i] == "--no-woff2" || args[i] == "-no-woff2" {
-			skipMimetype("application/font-woff", &s)
-			skipMimetype("font/woff2", &s)
-		} else if args[i] == "--no-ttf" || args[i] == "-no-ttf" {
-			skipMimetype("application/font-sfnt", &s)
-			skipMimetype("font/ttf", &s)
-		} else if args[i] == "--no-otf" || args[i] == "-no-otf" {
-			skipMimetype("application/font-sfnt", &s)
-			skipMimetype("font/otf", &s)
-		} else if args[i] == "--no-css" || args[i] == "-no-css" {
-			skipMimetype("text/css", &s)
-			skipMimetype("text/css;charset=UTF-8", &s)
-		} else if args[i] == "--no-html" || args[i] == "-no-html" {
-			skipMimetype("text/html", &s)
-			skipMimetype("text/html;charset=UTF-8", &s)
-		} else if args[i] == "--no-js" || args[i] == "-no-js" {
-			skipMimetype("text/javascript", &s)
-			skipMimetype("application/javascript", &s)
-			skipMimetype("application/x-javascript", &s)
-		} else if args[i] == "--no-json" || args[i] == "-no-json" {
-			skipMimetype("application/json", &s)
-		} else {
-			log.Error("invalid parameter: " + args[i] + "\n")
-			showOptions()
-		}
-	}
-
-	if recurse_arg != "0" {
-		if s.Origin == "" {
-			log.Error("missing parameter: -origin\n")
-			showOptions()
-		} else {
-			if regexp.MustCompile(`(?i)^(?:http[s]?://|file://[/]?)[a-z0-9]`).FindString(s.Origin) == "" {
-				log.Error("invalid origin url: " + s.Origin + "\n")
-				showOptions()
-			}
-
-			r := regexp.MustCompile(`(?i)^http[s]?://[^/]+`)
-			host := r.FindString(s.Origin)
-			path := r.ReplaceAllString(s.Origin, "")
-
-			if path == "" {
-				s.Origin = s.Origin + "/"
-			} else {
-				stripped_path := regexp.MustCompile(`[/]?[^/]*$`).ReplaceAllString(path, "/")
-
-				s.Origin = host + stripped_path
-			}
-		}
+func DefaultOptions() *Options {
+	return &Options { 
+		DefaultAccept(),   // Accept []string
+		[]string{},        // Reject []string
+		RetryPolicy {      // Retry RetryPolicy
+			3,                       // Attempts int
+			500 * time.Millisecond,  // Delay time.Duration
+			30 * time.Second,        // MaxDelay time.Duration
+			0.5,                     // Jitter float64
+		},
+		"",                // Placeholder string
+		&Summary{},        // Summary *Summary
+		nil,               // Confirm func(count int) bool
+		nil,               // Fetcher Fetcher
+		"",                // Root string
+		false,             // KeepReferences bool
+		nil,               // Recorder *Recorder
+		Rules{},           // Rules Rules
+		DeniedKeep,        // Denied string
+		Scope {            // Scope Scope
+			ScopeAny,                // Mode string
+			[]string{},              // Hosts []string
+			"",                      // Origin string
+		},
+		Limits {           // Limits Limits
+			0,                       // Resource int64
+			0,                       // Total int64
+			map[string]int64{},      // Classes map[string]int64
+			OversizeSkip,            // Oversize string
+		},
+		http.Header{},     // Headers http.Header
+		nil,               // Jar http.CookieJar
+		"chrome",          // Browser string
+		"",                // UserAgent string
+		nil,               // Transport http.RoundTripper
+		nil,               // Client *http.Client
+		"",                // Cache string
+		false,             // Offline bool
 	}
+}
 
-	if s.Source == "" {
-		log.Error("missing parameter: -source\n")
-		showOptions()
-	} else {
-		source, err := ioutil.ReadFile(s.Source)
-		if err != nil {
-			log.Error("invalid source file: " + s.Source + " (" + err.Error() + ")\n")
-			showOptions()
-		}
+// Reports whether source is an http(s) or file URL rather than a path.
+func IsURL(source string) bool {
+	return regexp.MustCompile(`(?i)^(?:http[s]?://|file://[/]?)[a-z0-9]`).FindString(source) != ""
+}
 
-		s.Body = source
+// Validates an origin URL and strips the file name from its path, so
+// relative paths found in the source resolve against its directory.
+func NormalizeOrigin(origin string) (string, error) {
+	if !IsURL(origin) {
+		return "", &ConfigError{"invalid origin url", origin, nil}
 	}
 
-	if recurse_arg != "" {
-		i, err := strconv.Atoi(recurse_arg)
-		if err != nil {
-			log.Error("invalid number of recursions: " + recurse_arg + " (" + err.Error() + ")\n")
-		}
+	r := regexp.MustCompile(`(?i)^http[s]?://[^/]+`)
+	host := r.FindString(origin)
+	path := r.ReplaceAllString(origin, "")
 
-		s.Recurse = i
+	if path == "" {
+		return origin + "/", nil
 	}
 
-	if cores_arg != "" {
-		i, err := strconv.Atoi(cores_arg)
-		if err != nil {
-			log.Error("invalid number of processes: " + cores_arg + " (" + err.Error() + ")\n")
-		}
-
-		Cores = i
-	}
+	stripped_path := regexp.MustCompile(`[/]?[^/]*$`).ReplaceAllString(path, "/")
 
-	return s
+	return host + stripped_path, nil
 }
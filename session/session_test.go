@@ -0,0 +1,101 @@
+package session
+
+import(
+	"testing"
+)
+
+func TestAccepts(t *testing.T) {
+	tests := []struct {
+		accept []string
+		reject []string
+		mimetype string
+		accepts bool
+	}{
+		{[]string{"image/png"}, nil, "image/png", true},
+		{[]string{"image/png"}, nil, "IMAGE/PNG; charset=binary", true},
+		{[]string{"image/png"}, nil, "image/gif", false},
+		{[]string{"image/*"}, nil, "image/gif", true},
+		{[]string{"image/*"}, nil, "imagefoo/gif", false},
+		{[]string{"*/*"}, nil, "text/css", true},
+		{[]string{"*"}, nil, "unknown", true},
+		{[]string{"image/*"}, []string{"image/png"}, "image/png", false},
+		{[]string{"image/png"}, []string{"image/*"}, "image/png", true},
+		{[]string{"image/*"}, []string{"image/*"}, "image/png", false},
+		{[]string{"image/*"}, []string{"*/*"}, "image/png", true},
+		{[]string{"image/*"}, []string{"*/*"}, "text/css", false},
+		{[]string{"image/*"}, []string{"*/*"}, "unknown", false},
+		{[]string{"unknown"}, []string{"*/*"}, "unknown", true},
+		{nil, nil, "image/png", false},
+	}
+
+	for _, test := range tests {
+		o := &Options{Accept: test.accept, Reject: test.reject}
+
+		if o.Accepts(test.mimetype) != test.accepts {
+			t.Errorf("accept %v, reject %v: Accepts(%q) = %t, expected %t", test.accept, test.reject, test.mimetype, !test.accepts, test.accepts)
+		}
+	}
+}
+
+func TestRejectMimetypes(t *testing.T) {
+	tests := []struct {
+		args []string
+		accepted []string
+		rejected []string
+	}{
+		{
+			[]string{},
+			[]string{"unknown", "image/png", "font/woff2", "text/css"},
+			[]string{"video/x-unknown"},
+		},
+		{
+			[]string{"-reject", "*/*", "-accept", "image/*"},
+			[]string{"image/png", "image/svg+xml", "image/x-unknown"},
+			[]string{"text/css", "font/woff2", "unknown", "application/octet-stream"},
+		},
+		{
+			[]string{"-accept", "image/*", "-reject", "*/*"},
+			nil,
+			[]string{"image/png", "text/css", "unknown"},
+		},
+		{
+			[]string{"-no-woff2"},
+			[]string{"font/woff", "font/ttf", "image/png"},
+			[]string{"font/woff2", "application/font-woff"},
+		},
+		{
+			[]string{"-reject", "image/*", "-accept", "image/png"},
+			[]string{"image/png", "text/css", "unknown"},
+			[]string{"image/gif", "image/svg+xml"},
+		},
+		{
+			[]string{"-no-unknown"},
+			[]string{"image/png"},
+			[]string{"unknown", "application/octet-stream"},
+		},
+		{
+			[]string{"-reject", "*/*", "-accept", "image/*", "-accept", "unknown"},
+			[]string{"image/png", "unknown"},
+			[]string{"text/css"},
+		},
+	}
+
+	for _, test := range tests {
+		s, _, err := NewSession(append(test.args, "-source", "https://example.com/"))
+		if err != nil {
+			t.Fatalf("%v: NewSession() returned %v", test.args, err)
+		}
+
+		for _, mimetype := range test.accepted {
+			if !s.Accepts(mimetype) {
+				t.Errorf("%v: %s is rejected, expected it to be accepted", test.args, mimetype)
+			}
+		}
+
+		for _, mimetype := range test.rejected {
+			if s.Accepts(mimetype) {
+				t.Errorf("%v: %s is accepted, expected it to be rejected", test.args, mimetype)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package session
+
+/*
+*	
+*	Transport
+*	
+*	Builds the HTTP transport requests are sent through, tuned for the
+*	many parallel requests of a page, from the proxy and TLS settings of
+*	the command line.
+*	
+*/
+
+import(
+	"net"
+	"time"
+	"net/url"
+	"net/http"
+	"io/ioutil"
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// Timeouts of the transports made by NewTransport.
+var (
+	DialTimeout = 15 * time.Second
+	TLSHandshakeTimeout = 10 * time.Second
+	ResponseHeaderTimeout = 60 * time.Second
+)
+
+type TransportConfig struct {
+	Proxy string          // http, https or socks5 URL, "" for HTTP_PROXY and HTTPS_PROXY
+	CAFiles []string      // PEM bundles trusted along with the system roots
+	CertFile string       // PEM client certificate
+	KeyFile string        // PEM key of CertFile, "" if CertFile holds it
+	Insecure bool         // skip certificate verification
+}
+
+// Returns a transport that sends requests through the proxy of config, or
+// the one in the environment, and verifies servers as set by config.
+func NewTransport(config TransportConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	// pages request many resources from few hosts at once, so more idle
+	// connections are kept per host than the 2 of the default transport
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 16
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.DialContext = (&net.Dialer{Timeout: DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = ResponseHeaderTimeout
+
+	// custom TLS settings turn HTTP/2 off unless forced
+	transport.ForceAttemptHTTP2 = true
+
+	if config.Proxy != "" {
+		proxy, err := url.Parse(config.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, &ConfigError{"invalid proxy", config.Proxy, err}
+		}
+
+		switch proxy.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, &ConfigError{"unsupported proxy scheme", proxy.Scheme, nil}
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if len(config.CAFiles) == 0 && config.CertFile == "" && !config.Insecure {
+		return transport, nil
+	}
+
+	tls_config := &tls.Config{InsecureSkipVerify: config.Insecure}
+
+	if len(config.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		for i := 0; i < len(config.CAFiles); i++ {
+			pem, err := ioutil.ReadFile(config.CAFiles[i])
+			if err != nil {
+				return nil, &ConfigError{"invalid CA bundle", config.CAFiles[i], err}
+			}
+
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, &ConfigError{"no certificates in CA bundle", config.CAFiles[i], nil}
+			}
+		}
+
+		tls_config.RootCAs = pool
+	}
+
+	if config.CertFile != "" {
+		key_file := config.KeyFile
+		if key_file == "" {
+			key_file = config.CertFile
+		}
+
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, key_file)
+		if err != nil {
+			return nil, &ConfigError{"invalid client certificate", config.CertFile, err}
+		}
+
+		tls_config.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport.TLSClientConfig = tls_config
+
+	return transport, nil
+}
+
+// Used by sessions without a transport of their own.
+var DefaultTransport, _ = NewTransport(TransportConfig{})
@@ -0,0 +1,98 @@
+package warc
+
+/*
+*	
+*	Reads WARC files, plain or as a series of gzip members.
+*	
+*/
+
+import(
+	"io"
+	"bytes"
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+	"net/textproto"
+	"compress/gzip"
+)
+
+var (
+	ErrFormat = errors.New("not a WARC file")
+)
+
+// Largest record block that is read, larger ones are taken for corrupt.
+var MaxBlockSize int64 = 1 << 30
+
+type Record struct {
+	Header textproto.MIMEHeader
+	Block []byte
+}
+
+func (record *Record) Type() string {
+	return record.Header.Get("WARC-Type")
+}
+
+func (record *Record) TargetURI() string {
+	return strings.Trim(record.Header.Get("WARC-Target-URI"), "<>")
+}
+
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+
+		br = bufio.NewReader(gz)
+	}
+
+	return &Reader{br}, nil
+}
+
+// Returns the next record, or io.EOF once every record has been read.
+func (reader *Reader) Next() (*Record, error) {
+	line := ""
+
+	for line == "" {
+		str, err := reader.r.ReadString('\n')
+		if err != nil && (err != io.EOF || strings.TrimSpace(str) == "") {
+			return nil, err
+		}
+
+		line = strings.TrimSpace(str)
+	}
+
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, ErrFormat
+	}
+
+	header, err := textproto.NewReader(reader.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 || length > MaxBlockSize {
+		return nil, ErrFormat
+	}
+
+	// grows with the data that is actually there rather than with length
+	var block bytes.Buffer
+
+	_, err = io.CopyN(&block, reader.r, length)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &Record{header, block.Bytes()}, nil
+}
@@ -0,0 +1,208 @@
+package warc
+
+/*
+*	
+*	WARC archives
+*	
+*	Writes recorded HTTP exchanges as WARC 1.1 request, response and
+*	metadata records that can be replayed by pywb compatible tools.
+*	
+*/
+
+import(
+	"io"
+	"time"
+	"bytes"
+	"strconv"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"compress/gzip"
+	"encoding/base32"
+
+	"github.com/buffermet/epoxy/session"
+)
+
+type Writer struct {
+	w io.Writer
+	compress bool
+}
+
+// Returns a Writer that writes records to w, compressing every record as
+// a gzip member of its own if compress is set, as is customary for
+// .warc.gz files.
+func NewWriter(w io.Writer, compress bool) *Writer {
+	return &Writer{w, compress}
+}
+
+func newRecordID() string {
+	uuid := make([]byte, 16)
+	rand.Read(uuid)
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+	str := hex.EncodeToString(uuid)
+
+	return "<urn:uuid:" + str[0:8] + "-" + str[8:12] + "-" + str[12:16] + "-" + str[16:20] + "-" + str[20:] + ">"
+}
+
+func digest(block []byte) string {
+	sum := sha1.Sum(block)
+
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+func formatDate(date time.Time) string {
+	return date.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// Writes a single record, fields are written in the given order after the
+// WARC-Type, WARC-Record-ID and WARC-Date fields.
+func (w *Writer) writeRecord(record_type, record_id string, date time.Time, fields [][2]string, block []byte) error {
+	var record bytes.Buffer
+
+	record.WriteString("WARC/1.1\r\n")
+	record.WriteString("WARC-Type: " + record_type + "\r\n")
+	record.WriteString("WARC-Record-ID: " + record_id + "\r\n")
+	record.WriteString("WARC-Date: " + formatDate(date) + "\r\n")
+
+	for i := 0; i < len(fields); i++ {
+		record.WriteString(fields[i][0] + ": " + fields[i][1] + "\r\n")
+	}
+
+	record.WriteString("WARC-Block-Digest: " + digest(block) + "\r\n")
+	record.WriteString("Content-Length: " + strconv.Itoa(len(block)) + "\r\n")
+	record.WriteString("\r\n")
+	record.Write(block)
+	record.WriteString("\r\n\r\n")
+
+	if !w.compress {
+		_, err := w.w.Write(record.Bytes())
+		return err
+	}
+
+	gz := gzip.NewWriter(w.w)
+
+	_, err := gz.Write(record.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// Writes the warcinfo record that describes the file.
+func (w *Writer) WriteInfo(filename string) error {
+	fields := [][2]string {
+		{"WARC-Filename", filename},
+		{"Content-Type", "application/warc-fields"},
+	}
+
+	block := []byte("software: epoxy\r\n" +
+	                "format: WARC File Format 1.1\r\n" +
+	                "conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n")
+
+	return w.writeRecord("warcinfo", newRecordID(), time.Now(), fields, block)
+}
+
+// Writes the response, request and metadata records of an exchange. The
+// request record is left out for exchanges answered from a cache, as no
+// request was sent.
+func (w *Writer) WriteExchange(exchange session.Exchange) error {
+	response_id := newRecordID()
+
+	response_block := append(append([]byte{}, exchange.ResponseHeader...), exchange.Body...)
+
+	err := w.writeRecord("response", response_id, exchange.Date, [][2]string {
+		{"WARC-Target-URI", exchange.URL},
+		{"WARC-Payload-Digest", digest(exchange.Body)},
+		{"Content-Type", "application/http;msgtype=response"},
+	}, response_block)
+	if err != nil {
+		return err
+	}
+
+	metadata := "fetchTimeMs: " + strconv.FormatInt(int64(exchange.Duration / time.Millisecond), 10) + "\r\n"
+
+	if exchange.Cached {
+		metadata = "servedFrom: cache\r\n"
+	} else {
+		err = w.writeRecord("request", newRecordID(), exchange.Date, [][2]string {
+			{"WARC-Target-URI", exchange.URL},
+			{"WARC-Concurrent-To", response_id},
+			{"Content-Type", "application/http;msgtype=request"},
+		}, exchange.RequestHeader)
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.writeRecord("metadata", newRecordID(), exchange.Date, [][2]string {
+		{"WARC-Target-URI", exchange.URL},
+		{"WARC-Concurrent-To", response_id},
+		{"Content-Type", "application/warc-fields"},
+	}, []byte(metadata))
+}
+
+// Writes a resource record for content that was not retrieved over HTTP,
+// such as a local source file or a resource read from a mirror.
+func (w *Writer) WriteResource(resource session.Resource, date time.Time) error {
+	mimetype := resource.Type
+	if mimetype == "" {
+		mimetype = "application/octet-stream"
+	}
+
+	return w.writeRecord("resource", newRecordID(), date, [][2]string {
+		{"WARC-Target-URI", resource.Address},
+		{"Content-Type", mimetype},
+	}, resource.Body)
+}
+
+// Writes a complete WARC file: a warcinfo record, every recorded exchange,
+// and resource records for root and any resource that has no exchange.
+func Write(w io.Writer, filename string, compress bool, root session.Resource, resources []session.Resource, exchanges []session.Exchange) error {
+	writer := NewWriter(w, compress)
+
+	err := writer.WriteInfo(filename)
+	if err != nil {
+		return err
+	}
+
+	recorded := map[string]bool{}
+
+	for i := 0; i < len(exchanges); i++ {
+		recorded[exchanges[i].URL] = true
+
+		err = writer.WriteExchange(exchanges[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	date := time.Now()
+
+	if !recorded[root.Address] {
+		recorded[root.Address] = true
+
+		err = writer.WriteResource(root, date)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < len(resources); i++ {
+		if resources[i].Placeholder || recorded[resources[i].Address] {
+			continue
+		}
+
+		recorded[resources[i].Address] = true
+
+		err = writer.WriteResource(resources[i], date)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}